@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// 访问权限
+const (
+	permZip   = "zip"   // 转换并下载ZIP
+	permLocal = "local" // 保存到服务器本地文件夹
+)
+
+// permSet 凭据拥有的权限
+type permSet map[string]bool
+
+// allPermissions 未启用认证或凭据未限定权限时拥有全部权限
+var allPermissions = permSet{permZip: true, permLocal: true}
+
+// basicUser Basic认证账号
+type basicUser struct {
+	password string
+	perms    permSet
+}
+
+var (
+	// apiTokens API令牌及其权限，由 -token-file 配置
+	apiTokens map[string]permSet
+	// basicUsers Basic认证账号，由 -basic-auth-file 配置
+	basicUsers map[string]basicUser
+	// csrfToken 嵌入Web界面的CSRF令牌，每次启动重新生成
+	csrfToken = randomToken()
+)
+
+// permsKey 请求上下文中保存权限的键
+type permsKey struct{}
+
+// randomToken 生成128位随机十六进制字符串
+func randomToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// parsePermissions 解析权限列表，如 "zip"、"zip,local"；空字符串或 "all" 表示全部权限
+func parsePermissions(s string) (permSet, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "all" {
+		return allPermissions, nil
+	}
+	perms := make(permSet)
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p != permZip && p != permLocal {
+			return nil, fmt.Errorf("未知的权限: %s（可用: zip, local, all）", p)
+		}
+		perms[p] = true
+	}
+	return perms, nil
+}
+
+// readCredentialFile 读取凭据文件，每行 "<凭据> [权限]"，忽略空行和 # 开头的注释
+func readCredentialFile(path string, add func(secret string, perms permSet) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 2 {
+			return fmt.Errorf("第 %d 行格式错误", n)
+		}
+		perms := allPermissions
+		if len(fields) == 2 {
+			if perms, err = parsePermissions(fields[1]); err != nil {
+				return fmt.Errorf("第 %d 行: %w", n, err)
+			}
+		}
+		if err := add(fields[0], perms); err != nil {
+			return fmt.Errorf("第 %d 行: %w", n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// readTokenFile 读取API令牌文件，每行 "<令牌> [权限]"
+func readTokenFile(path string) (map[string]permSet, error) {
+	tokens := make(map[string]permSet)
+	err := readCredentialFile(path, func(token string, perms permSet) error {
+		tokens[token] = perms
+		return nil
+	})
+	return tokens, err
+}
+
+// readBasicAuthFile 读取Basic认证文件，每行 "<用户名>:<密码> [权限]"
+func readBasicAuthFile(path string) (map[string]basicUser, error) {
+	users := make(map[string]basicUser)
+	err := readCredentialFile(path, func(cred string, perms permSet) error {
+		name, password, ok := strings.Cut(cred, ":")
+		if !ok || name == "" {
+			return errors.New("应为 用户名:密码")
+		}
+		users[name] = basicUser{password: password, perms: perms}
+		return nil
+	})
+	return users, err
+}
+
+// authEnabled 是否配置了任何凭据
+func authEnabled() bool {
+	return len(apiTokens) > 0 || len(basicUsers) > 0
+}
+
+// authenticate 校验请求的凭据，返回权限以及凭据是否为API令牌。
+// 未配置凭据时所有请求都拥有全部权限。
+func authenticate(r *http.Request) (permSet, bool, bool) {
+	if !authEnabled() {
+		return allPermissions, false, true
+	}
+
+	token := r.Header.Get("X-API-Token")
+	if v, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = strings.TrimSpace(v)
+	}
+	if token != "" {
+		for t, perms := range apiTokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				return perms, true, true
+			}
+		}
+		return nil, false, false
+	}
+
+	if name, password, ok := r.BasicAuth(); ok {
+		user, found := basicUsers[name]
+		if found && subtle.ConstantTimeCompare([]byte(user.password), []byte(password)) == 1 {
+			return user.perms, false, true
+		}
+	}
+	return nil, false, false
+}
+
+// checkCSRF 浏览器发起的修改请求必须来自同源页面并携带CSRF令牌。
+// 不带 Origin 和 Sec-Fetch-Site 的非浏览器客户端（如curl）不受影响。
+func checkCSRF(r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+
+	origin := r.Header.Get("Origin")
+	site := r.Header.Get("Sec-Fetch-Site")
+	if origin == "" && site == "" {
+		return nil
+	}
+	if site != "" && site != "same-origin" && site != "none" {
+		return errors.New("拒绝跨站请求")
+	}
+	if origin != "" {
+		if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+			return errors.New("拒绝跨站请求")
+		}
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-CSRF-Token")), []byte(csrfToken)) != 1 {
+		return errors.New("缺少或无效的CSRF令牌，请刷新页面后重试")
+	}
+	return nil
+}
+
+// requireAuth 校验所有请求的凭据和CSRF令牌，并把权限保存到请求上下文
+func requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		perms, bearer, ok := authenticate(r)
+		if !ok {
+			if len(basicUsers) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="pdf2txt", charset="UTF-8"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="pdf2txt"`)
+			}
+			writeJSONError(w, "需要认证", http.StatusUnauthorized)
+			return
+		}
+
+		// API令牌不会被浏览器自动携带，不需要CSRF保护
+		if !bearer {
+			if err := checkCSRF(r); err != nil {
+				writeJSONError(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), permsKey{}, perms)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// hasPermission 判断请求的凭据是否拥有指定权限
+func hasPermission(r *http.Request, perm string) bool {
+	perms, ok := r.Context().Value(permsKey{}).(permSet)
+	if !ok {
+		return !authEnabled()
+	}
+	return perms[perm]
+}
+
+// permissionError 凭据缺少权限时的错误信息
+func permissionError(perm string) string {
+	return fmt.Sprintf("当前凭据没有 %s 权限", perm)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withCredentials 在测试期间替换全局凭据
+func withCredentials(t *testing.T, tokens map[string]permSet, users map[string]basicUser) {
+	t.Helper()
+	savedTokens, savedUsers := apiTokens, basicUsers
+	t.Cleanup(func() { apiTokens, basicUsers = savedTokens, savedUsers })
+	apiTokens, basicUsers = tokens, users
+}
+
+func TestParsePermissions(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{in: "", want: []string{permZip, permLocal}},
+		{in: "all", want: []string{permZip, permLocal}},
+		{in: "zip", want: []string{permZip}},
+		{in: " zip , local ", want: []string{permZip, permLocal}},
+		{in: "zip,admin", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			perms, err := parsePermissions(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsePermissions(%q) 应返回错误", tt.in)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(perms) != len(tt.want) {
+				t.Fatalf("parsePermissions(%q) = %v，want %v", tt.in, perms, tt.want)
+			}
+			for _, p := range tt.want {
+				if !perms[p] {
+					t.Errorf("parsePermissions(%q) 缺少 %s", tt.in, p)
+				}
+			}
+		})
+	}
+}
+
+func TestReadCredentialFiles(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "tokens")
+	os.WriteFile(tokenFile, []byte("# 注释\n\nt1\nt2 zip\n"), 0o600)
+	tokens, err := readTokenFile(tokenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tokens["t1"][permLocal] || !tokens["t2"][permZip] || tokens["t2"][permLocal] {
+		t.Errorf("readTokenFile = %v", tokens)
+	}
+
+	userFile := filepath.Join(dir, "users")
+	os.WriteFile(userFile, []byte("alice:a:b local\n"), 0o600)
+	users, err := readBasicAuthFile(userFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := users["alice"]; u.password != "a:b" || !u.perms[permLocal] || u.perms[permZip] {
+		t.Errorf("readBasicAuthFile = %+v", users)
+	}
+
+	for name, content := range map[string]string{
+		"缺少冒号":  "alice local\n",
+		"字段过多":  "alice:a zip local\n",
+		"未知权限":  "alice:a admin\n",
+		"用户名为空": ":a\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			os.WriteFile(userFile, []byte(content), 0o600)
+			if _, err := readBasicAuthFile(userFile); err == nil {
+				t.Errorf("readBasicAuthFile(%q) 应返回错误", content)
+			}
+		})
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	withCredentials(t,
+		map[string]permSet{"tok-zip": {permZip: true}},
+		map[string]basicUser{"alice": {password: "secret", perms: allPermissions}},
+	)
+
+	tests := []struct {
+		name       string
+		method     string
+		header     map[string]string
+		user, pass string
+		wantStatus int
+		wantLocal  bool
+	}{
+		{name: "无凭据", method: "GET", wantStatus: http.StatusUnauthorized},
+		{name: "错误令牌", method: "POST", header: map[string]string{"X-API-Token": "bad"}, wantStatus: http.StatusUnauthorized},
+		{name: "错误令牌不回退到Basic", method: "GET", header: map[string]string{"X-API-Token": "bad"}, user: "alice", pass: "secret", wantStatus: http.StatusUnauthorized},
+		{name: "错误密码", method: "GET", user: "alice", pass: "wrong", wantStatus: http.StatusUnauthorized},
+		{name: "X-API-Token", method: "POST", header: map[string]string{"X-API-Token": "tok-zip"}, wantStatus: http.StatusOK},
+		{name: "Bearer令牌", method: "POST", header: map[string]string{"Authorization": "Bearer tok-zip"}, wantStatus: http.StatusOK},
+		{name: "令牌跨站请求不检查CSRF", method: "POST", header: map[string]string{"X-API-Token": "tok-zip", "Sec-Fetch-Site": "cross-site"}, wantStatus: http.StatusOK},
+		{name: "Basic非浏览器客户端", method: "POST", user: "alice", pass: "secret", wantStatus: http.StatusOK, wantLocal: true},
+		{name: "Basic同源无CSRF令牌", method: "POST", user: "alice", pass: "secret", header: map[string]string{"Origin": "http://example.com"}, wantStatus: http.StatusForbidden},
+		{name: "Basic同源带CSRF令牌", method: "POST", user: "alice", pass: "secret", header: map[string]string{"Origin": "http://example.com", "X-CSRF-Token": csrfToken}, wantStatus: http.StatusOK, wantLocal: true},
+		{name: "Basic跨源带CSRF令牌", method: "POST", user: "alice", pass: "secret", header: map[string]string{"Origin": "http://evil.test", "X-CSRF-Token": csrfToken}, wantStatus: http.StatusForbidden},
+		{name: "Basic跨站带CSRF令牌", method: "POST", user: "alice", pass: "secret", header: map[string]string{"Sec-Fetch-Site": "cross-site", "X-CSRF-Token": csrfToken}, wantStatus: http.StatusForbidden},
+		{name: "Basic同站错误CSRF令牌", method: "POST", user: "alice", pass: "secret", header: map[string]string{"Sec-Fetch-Site": "same-origin", "X-CSRF-Token": "bad"}, wantStatus: http.StatusForbidden},
+		{name: "GET不检查CSRF", method: "GET", user: "alice", pass: "secret", header: map[string]string{"Sec-Fetch-Site": "cross-site"}, wantStatus: http.StatusOK, wantLocal: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotZip, gotLocal bool
+			h := requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotZip, gotLocal = hasPermission(r, permZip), hasPermission(r, permLocal)
+			}))
+
+			r := httptest.NewRequest(tt.method, "http://example.com/api/jobs", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			if tt.user != "" {
+				r.SetBasicAuth(tt.user, tt.pass)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("状态码 = %d，want %d（%s）", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 响应缺少 WWW-Authenticate")
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			if !gotZip || gotLocal != tt.wantLocal {
+				t.Errorf("权限 zip=%v local=%v，want zip=true local=%v", gotZip, gotLocal, tt.wantLocal)
+			}
+		})
+	}
+}
+
+func TestRequireAuthDisabled(t *testing.T) {
+	withCredentials(t, nil, nil)
+
+	var gotLocal bool
+	h := requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotLocal = hasPermission(r, permLocal)
+	}))
+
+	// 未启用认证时浏览器请求仍然需要CSRF令牌
+	r := httptest.NewRequest("POST", "http://example.com/api/jobs", nil)
+	r.Header.Set("Origin", "http://example.com")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("无CSRF令牌：状态码 = %d，want 403", w.Code)
+	}
+
+	r = httptest.NewRequest("POST", "http://example.com/api/jobs", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !gotLocal {
+		t.Errorf("非浏览器客户端：状态码 = %d，local = %v", w.Code, gotLocal)
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheVersion 缓存条目格式的版本，条目格式或提取逻辑变化时修改，旧条目自然失效
+const cacheVersion = "2"
+
+var (
+	// cacheEnabled 是否缓存提取结果，由 cache 配置。缓存中保存的是提取出的明文（包括用密码解密的文档），默认关闭
+	cacheEnabled = false
+	// cacheDir 缓存目录，由 cache_dir 配置
+	cacheDir = defaultCacheDir()
+	// cacheMaxSize 缓存目录的最大字节数，超出后删除最久未使用的条目，0 表示不限制；由 cache_max_mb 配置
+	cacheMaxSize int64 = 1 << 30
+)
+
+// cacheState 本进程的缓存统计，以及缓存目录总大小的估计值
+var cacheState struct {
+	mu        sync.Mutex
+	scanned   bool  // 是否已扫描过缓存目录
+	size      int64 // 缓存目录的总大小
+	hits      int64
+	misses    int64
+	stores    int64
+	evictions int64
+}
+
+// CacheStats 缓存统计。命中等计数只统计本进程，条目数和大小来自缓存目录
+type CacheStats struct {
+	Enabled   bool   `json:"enabled"`
+	Dir       string `json:"dir"`
+	Entries   int    `json:"entries"`
+	SizeBytes int64  `json:"sizeBytes"`
+	MaxBytes  int64  `json:"maxBytes"`
+	Hits      int64  `json:"hits"`
+	Misses    int64  `json:"misses"`
+	Stores    int64  `json:"stores"`
+	Evictions int64  `json:"evictions"`
+}
+
+// cacheEntry 缓存文件的内容；PageError 中的错误只保留错误码和信息
+type cacheEntry struct {
+	Backend    string            `json:"backend"`
+	Pages      []Page            `json:"pages"`
+	TotalPages int               `json:"totalPages,omitempty"`
+	Failed     []cachedPageError `json:"failed,omitempty"`
+	Meta       *DocumentMeta     `json:"meta,omitempty"`
+}
+
+type cachedPageError struct {
+	Number int       `json:"number"`
+	Code   ErrorCode `json:"code"`
+	Error  string    `json:"error"`
+}
+
+// defaultCacheDir 返回系统缓存目录下的 pdf2txt，无法确定时使用临时目录
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "pdf2txt-cache")
+	}
+	return filepath.Join(dir, "pdf2txt")
+}
+
+// cacheKey 计算PDF数据和影响提取结果的参数的SHA-256。输出格式本身不参与计算，但它决定是否提取
+// 行和单词的坐标（Markdown需要行的字号），这两项参与计算；
+// 密码参与计算，没有提供密码的请求不会命中用密码解密得到的结果
+func cacheKey(data []byte, opts ExtractOptions) string {
+	backends := opts.Backends
+	if len(backends) == 0 {
+		backends = defaultBackends
+	}
+	if opts.OCR && !slices.Contains(backends, "ocr") {
+		backends = append(slices.Clip(backends), "ocr")
+	}
+	lang := opts.OCRLanguages
+	if lang == "" {
+		lang = ocrLanguages
+	}
+
+	h := sha256.New()
+	h.Write(data)
+	fmt.Fprintf(h, "\x00v%s\x00%q\x00%q\x00%s\x00%t\x00%t\x00%t\x00%s\x00%t\x00%t",
+		cacheVersion, backends, candidatePasswords(opts), opts.PageRange, opts.wantLines(), opts.wantWords(),
+		opts.OCR, lang, opts.Meta, pdftotextLayout)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cachePath 缓存条目的路径，按前两位分子目录，避免单个目录中文件过多
+func cachePath(key string) string {
+	return filepath.Join(cacheDir, key[:2], key+".json.gz")
+}
+
+// loadCached 读取缓存的提取结果。命中时更新文件的修改时间，作为LRU淘汰的依据
+func loadCached(key string) (*ExtractResult, bool) {
+	if !cacheEnabled {
+		return nil, false
+	}
+	path := cachePath(key)
+	result, err := readCacheEntry(path)
+	cacheState.mu.Lock()
+	if err != nil {
+		cacheState.misses++
+	} else {
+		cacheState.hits++
+	}
+	cacheState.mu.Unlock()
+
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("读取缓存失败，已删除 %s: %v\n", path, err)
+			os.Remove(path)
+		}
+		return nil, false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return result, true
+}
+
+// readCacheEntry 解码缓存文件
+func readCacheEntry(path string) (*ExtractResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	var entry cacheEntry
+	if err := json.NewDecoder(zr).Decode(&entry); err != nil {
+		return nil, err
+	}
+
+	result := &ExtractResult{Backend: entry.Backend, Pages: entry.Pages, TotalPages: entry.TotalPages, Meta: entry.Meta}
+	for _, f := range entry.Failed {
+		result.Failed = append(result.Failed, PageError{Number: f.Number, Err: newConvertError(f.Code, "%s", f.Error)})
+	}
+	return result, nil
+}
+
+// storeCached 保存提取结果。先写临时文件再重命名，多个进程共用缓存目录时也不会读到不完整的条目
+func storeCached(key string, result *ExtractResult) {
+	if !cacheEnabled {
+		return
+	}
+	size, err := writeCacheEntry(cachePath(key), result)
+	if err != nil {
+		log.Printf("写入缓存失败: %v\n", err)
+		return
+	}
+
+	cacheState.mu.Lock()
+	cacheState.stores++
+	if !cacheState.scanned {
+		cacheState.scanned = true
+		_, cacheState.size, _ = cacheUsage()
+	} else {
+		cacheState.size += size
+	}
+	over := cacheMaxSize > 0 && cacheState.size > cacheMaxSize
+	cacheState.mu.Unlock()
+
+	if over {
+		evictCache()
+	}
+}
+
+// writeCacheEntry 编码并写入缓存文件，返回文件大小
+func writeCacheEntry(path string, result *ExtractResult) (int64, error) {
+	entry := cacheEntry{Backend: result.Backend, Pages: result.Pages, TotalPages: result.TotalPages, Meta: result.Meta}
+	for _, f := range result.Failed {
+		entry.Failed = append(entry.Failed, cachedPageError{Number: f.Number, Code: errorCode(f.Err), Error: f.Err.Error()})
+	}
+
+	// 缓存中是文档的明文，只允许当前用户读取
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	zw := gzip.NewWriter(tmp)
+	err = json.NewEncoder(zw).Encode(entry)
+	if err == nil {
+		err = zw.Close()
+	}
+	var info os.FileInfo
+	if err == nil {
+		info, err = tmp.Stat()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// cacheFile 缓存目录中的一个条目
+type cacheFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// listCache 列出缓存目录中的所有条目，目录不存在时返回空
+func listCache() ([]cacheFile, error) {
+	var files []cacheFile
+	err := filepath.WalkDir(cacheDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == cacheDir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".json.gz") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, cacheFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+// cacheUsage 返回缓存目录中的条目数和总大小
+func cacheUsage() (int, int64, error) {
+	files, err := listCache()
+	var size int64
+	for _, f := range files {
+		size += f.size
+	}
+	return len(files), size, err
+}
+
+// evictCache 按最近使用时间从旧到新删除条目，直到总大小降到上限的90%，避免每次写入都重新扫描
+func evictCache() {
+	files, err := listCache()
+	if err != nil {
+		log.Printf("扫描缓存目录失败: %v\n", err)
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	var size int64
+	for _, f := range files {
+		size += f.size
+	}
+	target := cacheMaxSize / 10 * 9
+	evicted := 0
+	for _, f := range files {
+		if size <= target {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		size -= f.size
+		evicted++
+	}
+
+	cacheState.mu.Lock()
+	cacheState.size = size
+	cacheState.evictions += int64(evicted)
+	cacheState.mu.Unlock()
+	log.Printf("缓存超过上限 %d MB，已删除 %d 个最久未使用的条目\n", cacheMaxSize>>20, evicted)
+}
+
+// purgeCache 删除修改时间早于 olderThan 之前的条目，olderThan 为0时删除全部，返回删除的条目数和大小
+func purgeCache(olderThan time.Duration) (int, int64, error) {
+	files, err := listCache()
+	if err != nil {
+		return 0, 0, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	removed, freed := 0, int64(0)
+	for _, f := range files {
+		if olderThan > 0 && f.modTime.After(cutoff) {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil {
+			return removed, freed, err
+		}
+		removed++
+		freed += f.size
+	}
+
+	cacheState.mu.Lock()
+	cacheState.scanned = false
+	cacheState.mu.Unlock()
+	return removed, freed, nil
+}
+
+// cacheStats 返回缓存统计
+func cacheStats() (CacheStats, error) {
+	entries, size, err := cacheUsage()
+	cacheState.mu.Lock()
+	defer cacheState.mu.Unlock()
+	return CacheStats{
+		Enabled:   cacheEnabled,
+		Dir:       cacheDir,
+		Entries:   entries,
+		SizeBytes: size,
+		MaxBytes:  cacheMaxSize,
+		Hits:      cacheState.hits,
+		Misses:    cacheState.misses,
+		Stores:    cacheState.stores,
+		Evictions: cacheState.evictions,
+	}, err
+}
+
+// cacheStatsHandler 返回缓存统计
+func cacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := cacheStats()
+	if err != nil {
+		writeJSONError(w, fmt.Sprintf("读取缓存目录失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// runCache 执行 cache 子命令：stats 显示缓存统计，purge 清空缓存
+func runCache(args []string) int {
+	const usage = "用法: pdf2txt cache stats|purge [-config 配置文件] [-cache-dir 目录] [-older-than 时长]"
+	if len(args) == 0 || (args[0] != "stats" && args[0] != "purge") {
+		fmt.Fprintln(os.Stderr, usage)
+		return exitFailed
+	}
+
+	flags := flag.NewFlagSet("cache "+args[0], flag.ContinueOnError)
+	configPath := flags.String("config", "", "配置文件（.yaml/.yml/.toml），也可通过 PDF2TXT_CONFIG 指定")
+	dir := flags.String("cache-dir", "", "缓存目录（默认使用配置文件或 PDF2TXT_CACHE_DIR 中的 cache_dir）")
+	olderThan := flags.Duration("older-than", 0, "purge 时只删除超过该时长未使用的条目，如 720h（默认全部删除）")
+	if err := flags.Parse(args[1:]); err != nil {
+		return exitFailed
+	}
+
+	// 与 serve 相同的优先级：命令行参数、环境变量、配置文件、默认值
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+	if *dir != "" {
+		cfg.CacheDir = *dir
+	}
+	if cfg.CacheDir == "" {
+		fmt.Fprintln(os.Stderr, "cache_dir 不能为空")
+		return exitFailed
+	}
+	cacheDir = expandHome(cfg.CacheDir)
+
+	if args[0] == "purge" {
+		removed, freed, err := purgeCache(*olderThan)
+		fmt.Printf("已删除 %s 中的 %d 个缓存条目，释放 %.1f MB\n", cacheDir, removed, float64(freed)/(1<<20))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "清理缓存失败: %v\n", err)
+			return exitFailed
+		}
+		return exitOK
+	}
+
+	entries, size, err := cacheUsage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取缓存目录失败: %v\n", err)
+		return exitFailed
+	}
+	fmt.Printf("缓存目录: %s\n条目数: %d\n大小: %.1f MB\n", cacheDir, entries, float64(size)/(1<<20))
+	return exitOK
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// withCache 在测试期间启用缓存，使用临时目录并清空本进程的统计
+func withCache(t *testing.T, maxSize int64) {
+	t.Helper()
+	enabled, dir, limit := cacheEnabled, cacheDir, cacheMaxSize
+	t.Cleanup(func() { cacheEnabled, cacheDir, cacheMaxSize = enabled, dir, limit })
+	cacheEnabled, cacheDir, cacheMaxSize = true, t.TempDir(), maxSize
+
+	cacheState.mu.Lock()
+	defer cacheState.mu.Unlock()
+	cacheState.scanned, cacheState.size = false, 0
+	cacheState.hits, cacheState.misses, cacheState.stores, cacheState.evictions = 0, 0, 0, 0
+}
+
+func TestCacheKey(t *testing.T) {
+	data := []byte("%PDF-1.7 test")
+	base := ExtractOptions{Format: formatText}
+	pages, _ := parsePageRange("1-3")
+
+	tests := []struct {
+		name string
+		opts ExtractOptions
+		same bool // 是否与 txt 输出共用缓存
+	}{
+		{"json 不带坐标", ExtractOptions{Format: formatJSON}, true},
+		{"txt 带行坐标", ExtractOptions{Format: formatText, Positions: positionsLines}, false},
+		{"md 需要行", ExtractOptions{Format: formatMarkdown}, false},
+		{"json 带行", ExtractOptions{Format: formatJSON, Positions: positionsLines}, false},
+		{"json 带单词", ExtractOptions{Format: formatJSON, Positions: positionsWords}, false},
+		{"页码范围", ExtractOptions{Format: formatText, PageRange: pages}, false},
+		{"密码", ExtractOptions{Format: formatText, Passwords: []string{"secret"}}, false},
+		{"OCR", ExtractOptions{Format: formatText, OCR: true}, false},
+		{"元数据", ExtractOptions{Format: formatText, Meta: true}, false},
+		{"后端顺序", ExtractOptions{Format: formatText, Backends: []string{"pdftotext", "unipdf"}}, false},
+	}
+	want := cacheKey(data, base)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cacheKey(data, tt.opts); (got == want) != tt.same {
+				t.Fatalf("与 txt 输出的键相同 = %v，want %v", got == want, tt.same)
+			}
+		})
+	}
+
+	md := cacheKey(data, ExtractOptions{Format: formatMarkdown})
+	if lines := cacheKey(data, ExtractOptions{Format: formatJSON, Positions: positionsLines}); lines != md {
+		t.Error("md 与带行坐标的 json 提取的内容相同，应共用缓存")
+	}
+	if cacheKey([]byte("%PDF-1.7 other"), base) == want {
+		t.Error("不同的PDF内容不应共用缓存")
+	}
+}
+
+func TestRunCacheDirPrecedence(t *testing.T) {
+	saved := cacheDir
+	t.Cleanup(func() { cacheDir = saved })
+
+	base := t.TempDir()
+	config := filepath.Join(base, "pdf2txt.yaml")
+	if err := os.WriteFile(config, []byte("cache_dir: "+filepath.Join(base, "config")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{"配置文件", []string{"-config", config}, nil, "config"},
+		{"PDF2TXT_CONFIG", nil, map[string]string{"PDF2TXT_CONFIG": config}, "config"},
+		{"环境变量覆盖配置文件", []string{"-config", config}, map[string]string{"PDF2TXT_CACHE_DIR": filepath.Join(base, "env")}, "env"},
+		{"命令行参数优先", []string{"-config", config, "-cache-dir", filepath.Join(base, "flag")}, map[string]string{"PDF2TXT_CACHE_DIR": filepath.Join(base, "env")}, "flag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PDF2TXT_CONFIG", "")
+			t.Setenv("PDF2TXT_CACHE_DIR", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if code := runCache(append([]string{"stats"}, tt.args...)); code != exitOK {
+				t.Fatalf("退出码 = %d", code)
+			}
+			if want := filepath.Join(base, tt.want); cacheDir != want {
+				t.Fatalf("缓存目录 = %s，want %s", cacheDir, want)
+			}
+		})
+	}
+}
+
+func TestCacheStoreAndLoad(t *testing.T) {
+	withCache(t, 0)
+	key := cacheKey([]byte("%PDF-1.7 a"), ExtractOptions{Format: formatText})
+
+	if _, ok := loadCached(key); ok {
+		t.Fatal("空缓存不应命中")
+	}
+	storeCached(key, &ExtractResult{
+		Backend:    "pdftotext",
+		Pages:      []Page{{Number: 1, Text: "第一页"}},
+		TotalPages: 2,
+		Failed:     []PageError{{Number: 2, Err: newConvertError(CodeCorrupted, "第2页损坏")}},
+	})
+
+	result, ok := loadCached(key)
+	if !ok {
+		t.Fatal("写入后应命中缓存")
+	}
+	if result.Backend != "pdftotext" || result.TotalPages != 2 || len(result.Pages) != 1 || result.Pages[0].Text != "第一页" {
+		t.Errorf("缓存的结果 = %+v", result)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Number != 2 || errorCode(result.Failed[0].Err) != CodeCorrupted {
+		t.Errorf("缓存的失败页面 = %v", result.Failed)
+	}
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(cachePath(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Errorf("缓存文件权限 = %o，want 600", perm)
+		}
+	}
+
+	stats, err := cacheStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 1 || stats.Hits != 1 || stats.Misses != 1 || stats.Stores != 1 {
+		t.Errorf("统计 = %+v", stats)
+	}
+
+	// 损坏的条目视为未命中并被删除
+	os.WriteFile(cachePath(key), []byte("not gzip"), 0600)
+	if _, ok := loadCached(key); ok {
+		t.Fatal("损坏的条目不应命中")
+	}
+	if _, err := os.Stat(cachePath(key)); !os.IsNotExist(err) {
+		t.Errorf("损坏的条目未被删除: %v", err)
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	withCache(t, 0)
+	cacheEnabled = false
+
+	key := cacheKey([]byte("%PDF-1.7 a"), ExtractOptions{Format: formatText})
+	storeCached(key, &ExtractResult{Pages: []Page{{Number: 1, Text: "x"}}})
+	if entries, _, _ := cacheUsage(); entries != 0 {
+		t.Errorf("关闭缓存时写入了 %d 个条目", entries)
+	}
+}
+
+// cacheStub 第2页以 code 失败的提取后端，记录调用次数
+type cacheStub struct {
+	code  ErrorCode
+	calls *int
+}
+
+func (s cacheStub) Name() string               { return "stub-cache" }
+func (s cacheStub) Capabilities() Capabilities { return Capabilities{} }
+
+func (s cacheStub) Extract(ctx context.Context, data []byte, opts ExtractOptions) (*ExtractResult, error) {
+	*s.calls++
+	return &ExtractResult{
+		TotalPages: 2,
+		Pages:      []Page{{Number: 1, Text: "第一页"}},
+		Failed:     []PageError{{Number: 2, Err: newConvertError(s.code, "第2页失败")}},
+	}, nil
+}
+
+func TestConvertSkipsCacheForIncompleteResults(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      ErrorCode
+		wantCalls int // 转换两次时调用后端的次数
+	}{
+		{name: "页面损坏时缓存", code: CodeCorrupted, wantCalls: 1},
+		{name: "超时时不缓存", code: CodeTimeout, wantCalls: 2},
+		{name: "后端缺失时不缓存", code: CodeBackendMissing, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withCache(t, 0)
+			calls := 0
+			extractors["stub-cache"] = cacheStub{code: tt.code, calls: &calls}
+			defer delete(extractors, "stub-cache")
+
+			opts := ExtractOptions{Format: formatText, Backends: []string{"stub-cache"}}
+			for range 2 {
+				result, err := convertPDFReaderToText(context.Background(), bytes.NewReader([]byte("%PDF-1.7 a")), opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(result.Pages) != 1 || len(result.Failed) != 1 {
+					t.Fatalf("结果 = %+v", result)
+				}
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("调用后端 %d 次，want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	withCache(t, 0)
+	entry := &ExtractResult{Pages: []Page{{Number: 1, Text: "x"}}}
+	size, err := writeCacheEntry(filepath.Join(t.TempDir(), "probe.json.gz"), entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 能放下两个半条目，淘汰到90%后剩两个
+	cacheMaxSize = size*5/2 + 1
+
+	key := func(i int) string { return cacheKey([]byte{byte(i)}, ExtractOptions{Format: formatText}) }
+	for i := range 2 {
+		storeCached(key(i), entry)
+		old := time.Now().Add(time.Duration(i-10) * time.Minute)
+		os.Chtimes(cachePath(key(i)), old, old)
+	}
+	// 读取第0个条目后它成为最近使用的，第1个条目最先被淘汰
+	if _, ok := loadCached(key(0)); !ok {
+		t.Fatal("应命中缓存")
+	}
+	storeCached(key(2), entry)
+
+	for i, want := range []bool{true, false, true} {
+		if _, err := os.Stat(cachePath(key(i))); (err == nil) != want {
+			t.Errorf("第%d个条目存在 = %v，want %v", i, err == nil, want)
+		}
+	}
+	if stats, _ := cacheStats(); stats.Evictions != 1 {
+		t.Errorf("淘汰 %d 个条目，want 1", stats.Evictions)
+	}
+}
+
+func TestPurgeCache(t *testing.T) {
+	withCache(t, 0)
+
+	for i, age := range []time.Duration{0, 2 * time.Hour, 48 * time.Hour} {
+		key := cacheKey([]byte{byte(i)}, ExtractOptions{Format: formatText})
+		storeCached(key, &ExtractResult{Pages: []Page{{Number: 1, Text: "x"}}})
+		modTime := time.Now().Add(-age)
+		os.Chtimes(cachePath(key), modTime, modTime)
+	}
+
+	removed, freed, err := purgeCache(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 || freed <= 0 {
+		t.Errorf("删除 %d 个条目（%d 字节），want 2", removed, freed)
+	}
+	if removed, _, _ := purgeCache(0); removed != 1 {
+		t.Errorf("清空时删除 %d 个条目，want 1", removed)
+	}
+}
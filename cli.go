@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// 批量转换的退出码
+const (
+	exitOK      = 0 // 全部成功
+	exitPartial = 1 // 部分失败
+	exitFailed  = 2 // 全部失败或参数错误
+)
+
+// stringList 可重复指定的命令行参数
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// batchItem 待转换的单个PDF文件
+type batchItem struct {
+	Path   string // PDF文件路径
+	RelDir string // 相对于输入目录的子目录，用于在输出目录中还原结构
+}
+
+// outputDir 返回该文件的输出目录，outputDir 为空时输出到PDF所在目录
+func (item batchItem) outputDir(outputDir string) string {
+	if outputDir == "" {
+		return filepath.Dir(item.Path)
+	}
+	return filepath.Join(outputDir, item.RelDir)
+}
+
+// checkOutputCollisions 转换前检查不同的PDF是否会写入同一个输出文件（包括元数据文件），
+// 如多个输入目录中的同名文件输出到同一目录，避免结果被并发的转换互相覆盖
+func checkOutputCollisions(items []batchItem, outputDir string, opts ExtractOptions) error {
+	used := make(map[string]string)
+	for _, item := range items {
+		output := filepath.Join(item.outputDir(outputDir), outputName(filepath.Base(item.Path), opts.Format))
+		names := []string{output}
+		if opts.Meta {
+			names = append(names, metaName(output))
+		}
+		for _, name := range names {
+			key := name
+			if abs, err := filepath.Abs(name); err == nil {
+				key = abs
+			}
+			if other, ok := used[key]; ok {
+				return fmt.Errorf("输出文件重名: %s（%s 与 %s），没有转换任何文件", name, other, item.Path)
+			}
+			used[key] = item.Path
+		}
+	}
+	return nil
+}
+
+// batchFailure 单个文件的失败原因
+type batchFailure struct {
+	Path string
+	Err  error
+}
+
+// runConvert 执行 convert 子命令，返回进程退出码
+func runConvert(args []string) int {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "用法: pdf2txt convert <文件或目录...> [-o 输出目录] [选项]")
+		flags.PrintDefaults()
+	}
+	outputDir := flags.String("o", "", "输出目录（留空则输出到PDF所在目录）")
+	recursive := flags.Bool("r", true, "递归遍历子目录")
+	existing := flags.String("existing", existingOverwrite, "输出文件已存在时: overwrite 覆盖, skip 跳过, newer 仅在PDF更新时重新转换")
+	journalPath := flags.String("journal", "", "检查点文件，记录每个文件的处理结果（默认 <输出目录>/"+journalName+"，未指定 -o 时不记录）")
+	resume := flags.Bool("resume", false, "从检查点继续，已成功或已失败的文件不再转换")
+	retryFailed := flags.Bool("retry-failed", false, "从检查点继续，并重新转换检查点中失败的文件")
+	var includes, excludes stringList
+	flags.Var(&includes, "include", "只转换匹配该glob的文件（可重复，默认 *.pdf）")
+	flags.Var(&excludes, "exclude", "跳过匹配该glob的文件或目录（可重复）")
+	var ef extractFlags
+	ef.register(flags)
+	flags.IntVar(&maxBatchFiles, "max-batch-files", maxBatchFiles, "最多转换的PDF数，0 表示不限制")
+
+	inputs, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitFailed
+	}
+	if len(inputs) == 0 {
+		flags.Usage()
+		return exitFailed
+	}
+	if len(includes) == 0 {
+		includes = stringList{"*.pdf"}
+	}
+
+	if maxBatchFiles < 0 {
+		fmt.Fprintln(os.Stderr, "期限和资源限制不能小于0")
+		return exitFailed
+	}
+	opts, err := ef.options()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+	if *existing, err = parseExistingPolicy(*existing); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+
+	items, err := collectPDFs(inputs, *recursive, includes, excludes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "扫描输入失败: %v\n", err)
+		return exitFailed
+	}
+	if len(items) == 0 {
+		fmt.Fprintln(os.Stderr, "没有找到PDF文件")
+		return exitFailed
+	}
+	if err := checkBatchSize(len(items)); err != nil {
+		fmt.Fprintf(os.Stderr, "%v，可用 -max-batch-files 调整\n", err)
+		return exitFailed
+	}
+	if err := checkOutputCollisions(items, *outputDir, opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+
+	if *journalPath == "" && *outputDir != "" {
+		*journalPath = filepath.Join(*outputDir, journalName)
+	}
+	*resume = *resume || *retryFailed
+	if *resume && *journalPath == "" {
+		fmt.Fprintln(os.Stderr, "-resume 需要 -o 或 -journal 指定检查点")
+		return exitFailed
+	}
+	var jr *journal
+	if *journalPath != "" {
+		if jr, err = openCLIJournal(*journalPath, *outputDir, opts, *resume); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailed
+		}
+		defer jr.Close()
+	}
+
+	// Ctrl-C 后正在转换的文件立即中断，剩余文件不再处理
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make([]error, len(items))
+	skipped := make([]bool, len(items))
+	var resumed atomic.Int64
+	forEachParallel(workers, len(items), func(i int) {
+		item := items[i]
+		dir := item.outputDir(*outputDir)
+		output := filepath.Join(dir, outputName(filepath.Base(item.Path), opts.Format))
+
+		// 检查点以绝对路径记录文件，源文件的大小和修改时间用于判断记录是否过期
+		entry := journalEntry{Path: item.Path, FileResult: FileResult{Input: item.Path, Output: output}}
+		if abs, err := filepath.Abs(item.Path); err == nil {
+			entry.Path = abs
+		}
+		if info, err := os.Stat(item.Path); err == nil {
+			entry.Size, entry.ModTime = info.Size(), info.ModTime()
+		}
+		if e, ok := jr.resumed(entry.Path, entry.Size, entry.ModTime, *retryFailed); ok {
+			resumed.Add(1)
+			switch e.Status {
+			case statusFailed:
+				errs[i] = newConvertError(e.ErrorCode, "%s", e.Error)
+			case statusSkipped:
+				skipped[i] = true
+			}
+			return
+		}
+
+		if skipExistingFile(*existing, item.Path, output) {
+			skipped[i] = true
+			fmt.Printf("跳过（已有输出）: %s\n", item.Path)
+			entry.Status, entry.Skipped = statusSkipped, true
+			recordJournal(jr, entry)
+			return
+		}
+		if err := ctx.Err(); err != nil {
+			errs[i] = newConvertError(classifyError(err), "转换已取消: %w", err)
+		} else if err := os.MkdirAll(dir, 0755); err != nil {
+			errs[i] = newConvertError(CodeIO, "创建输出目录失败: %w", err)
+		} else {
+			errs[i] = convertPDFToText(ctx, item.Path, dir, opts)
+		}
+		if errs[i] != nil {
+			fmt.Fprintf(os.Stderr, "转换失败: %s: %v\n", item.Path, errs[i])
+			// 中断时未完成的文件不记录，恢复时重新转换
+			if ctx.Err() == nil {
+				entry.Status = statusFailed
+				entry.setError(errs[i])
+				recordJournal(jr, entry)
+			}
+			return
+		}
+		fmt.Printf("转换成功: %s\n", item.Path)
+		entry.Status = statusSuccess
+		recordJournal(jr, entry)
+	})
+	if n := resumed.Load(); n > 0 {
+		fmt.Printf("\n从检查点恢复: %d 个文件已处理过，未重新转换\n", n)
+	}
+
+	// 按输入顺序汇总失败
+	var failures []batchFailure
+	skippedCount := 0
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, batchFailure{Path: items[i].Path, Err: err})
+		}
+		if skipped[i] {
+			skippedCount++
+		}
+	}
+
+	return printSummary(len(items), skippedCount, failures)
+}
+
+// openCLIJournal 打开 convert 命令的检查点，输出目录记录为绝对路径
+func openCLIJournal(path, outputDir string, opts ExtractOptions, resume bool) (*journal, error) {
+	if outputDir != "" {
+		if abs, err := filepath.Abs(outputDir); err == nil {
+			outputDir = abs
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建检查点目录失败: %w", err)
+	}
+	open := func(flag int) (*os.File, error) {
+		return os.OpenFile(path, flag, 0644)
+	}
+	return openJournal(open, newJournalHeader(outputDir, opts), resume)
+}
+
+// recordJournal 记录单个文件的结果，写入失败只提示，不影响转换
+func recordJournal(jr *journal, entry journalEntry) {
+	if err := jr.record(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+}
+
+// extractFlags convert 和 watch 命令共用的提取参数
+type extractFlags struct {
+	passwords    stringList
+	passwordFile string
+	pages        string
+	format       string
+	positions    string
+	backends     string
+	ocr          bool
+	ocrLang      string
+	meta         bool
+	timeout      int
+	maxFileMB    int64
+	maxOutputMB  int64
+	cacheMaxMB   int64
+}
+
+// register 注册提取参数，并发数和页数限制直接写入全局变量
+func (f *extractFlags) register(flags *flag.FlagSet) {
+	flags.IntVar(&workers, "workers", workers, "并发转换的文件数")
+	flags.Var(&f.passwords, "password", "加密PDF的候选密码（可重复）")
+	flags.StringVar(&f.passwordFile, "password-file", "", "候选密码文件，每行一个")
+	flags.StringVar(&f.pages, "pages", "", "只提取指定页码，如 1-3,10,20-（默认全部页面）")
+	flags.StringVar(&f.format, "format", formatText, "输出格式: txt, json, md")
+	flags.StringVar(&f.positions, "positions", "", "JSON输出中包含文本坐标: none, lines, words, all")
+	flags.StringVar(&f.backends, "backends", strings.Join(defaultBackends, ","), "提取后端回退顺序，逗号分隔（可用: "+strings.Join(extractorNames(), ", ")+"）")
+	flags.BoolVar(&f.ocr, "ocr", ocrEnabled, "对没有文本层的页面执行OCR（需要 tesseract 和 pdftoppm 或 mutool）")
+	flags.StringVar(&f.ocrLang, "ocr-lang", ocrLanguages, "OCR识别语言，如 chi_sim+eng")
+	flags.BoolVar(&f.meta, "meta", false, "同时输出 <文件名>.meta.json 元数据（Info字典、XMP、页数、版本、加密方式）")
+	flags.IntVar(&f.timeout, "file-timeout", int(fileTimeout/time.Second), "单个文件的提取期限（秒），0 表示不限制")
+	flags.Int64Var(&f.maxFileMB, "max-file-size", maxFileSize>>20, "单个PDF的最大大小（MB），0 表示不限制")
+	flags.IntVar(&maxPages, "max-pages", maxPages, "单个PDF的最大页数，0 表示不限制")
+	flags.Int64Var(&f.maxOutputMB, "max-output", maxOutputSize>>20, "单个文件输出内容的最大大小（MB），0 表示不限制")
+	flags.BoolVar(&cacheEnabled, "cache", cacheEnabled, "按PDF内容和提取参数缓存提取结果，相同的文件不再重复提取（缓存中保存文档的明文）")
+	flags.StringVar(&cacheDir, "cache-dir", cacheDir, "缓存目录")
+	flags.Int64Var(&f.cacheMaxMB, "cache-max", cacheMaxSize>>20, "缓存的最大大小（MB），超出后删除最久未使用的条目，0 表示不限制")
+}
+
+// options 校验参数，设置期限和资源限制，返回提取选项
+func (f *extractFlags) options() (ExtractOptions, error) {
+	if f.timeout < 0 || f.maxFileMB < 0 || maxPages < 0 || f.maxOutputMB < 0 || f.cacheMaxMB < 0 {
+		return ExtractOptions{}, fmt.Errorf("期限和资源限制不能小于0")
+	}
+	fileTimeout = time.Duration(f.timeout) * time.Second
+	maxFileSize, maxOutputSize = f.maxFileMB<<20, f.maxOutputMB<<20
+	cacheDir, cacheMaxSize = expandHome(cacheDir), f.cacheMaxMB<<20
+
+	chain, err := parseBackends(f.backends)
+	if err != nil {
+		return ExtractOptions{}, err
+	}
+	pageRange, err := parsePageRange(f.pages)
+	if err != nil {
+		return ExtractOptions{}, err
+	}
+
+	opts := ExtractOptions{Backends: chain, Passwords: f.passwords, PageRange: pageRange, OCR: f.ocr, OCRLanguages: f.ocrLang, Meta: f.meta}
+	if opts.Format, err = parseFormat(f.format); err != nil {
+		return opts, err
+	}
+	if opts.Positions, err = parsePositions(f.positions); err != nil {
+		return opts, err
+	}
+	if f.passwordFile != "" {
+		filePasswords, err := readPasswordFile(f.passwordFile)
+		if err != nil {
+			return opts, fmt.Errorf("读取密码文件失败: %w", err)
+		}
+		opts.Passwords = append(opts.Passwords, filePasswords...)
+	}
+	return opts, nil
+}
+
+// parseInterspersed 解析参数，允许选项出现在位置参数之后
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// collectPDFs 展开输入的文件和目录，返回所有待转换的PDF
+func collectPDFs(inputs []string, recursive bool, includes, excludes []string) ([]batchItem, error) {
+	var items []batchItem
+	seen := make(map[string]bool)
+
+	add := func(path, relDir string) {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			abs = path
+		}
+		if seen[abs] {
+			return
+		}
+		seen[abs] = true
+		items = append(items, batchItem{Path: path, RelDir: relDir})
+	}
+
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			// 显式指定的文件只检查排除规则
+			if !matchAny(excludes, input, filepath.Base(input)) {
+				add(input, "")
+			}
+			continue
+		}
+
+		err = filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(input, path)
+			if d.IsDir() {
+				if path == input {
+					return nil
+				}
+				if !recursive || matchAny(excludes, rel, d.Name()) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !matchAny(includes, rel, d.Name()) || matchAny(excludes, rel, d.Name()) {
+				return nil
+			}
+			add(path, filepath.Dir(rel))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+// matchAny 判断相对路径或文件名是否匹配任一glob（不区分大小写）
+func matchAny(patterns []string, rel, name string) bool {
+	rel = strings.ToLower(filepath.ToSlash(rel))
+	name = strings.ToLower(name)
+	for _, p := range patterns {
+		p = strings.ToLower(filepath.ToSlash(p))
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(p, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// printSummary 输出批量转换汇总并返回退出码，跳过的文件不算失败
+func printSummary(total, skipped int, failures []batchFailure) int {
+	succeeded := total - skipped - len(failures)
+	if skipped > 0 {
+		fmt.Printf("\n转换完成: 共 %d, 成功 %d, 跳过 %d, 失败 %d\n", total, succeeded, skipped, len(failures))
+	} else {
+		fmt.Printf("\n转换完成: 共 %d, 成功 %d, 失败 %d\n", total, succeeded, len(failures))
+	}
+	if cacheEnabled {
+		cacheState.mu.Lock()
+		fmt.Printf("缓存: 命中 %d, 未命中 %d\n", cacheState.hits, cacheState.misses)
+		cacheState.mu.Unlock()
+	}
+	for _, f := range failures {
+		fmt.Printf("  失败: %s: [%s] %v\n", f.Path, errorCode(f.Err), f.Err)
+	}
+
+	switch {
+	case len(failures) == 0:
+		return exitOK
+	case succeeded == 0 && skipped == 0:
+		return exitFailed
+	default:
+		return exitPartial
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckOutputCollisions(t *testing.T) {
+	tests := []struct {
+		name      string
+		items     []batchItem
+		outputDir string
+		opts      ExtractOptions
+		conflict  string // 为空表示没有冲突
+	}{
+		{
+			name:      "不同目录的同名文件输出到同一目录",
+			items:     []batchItem{{Path: "in1/report.pdf", RelDir: "."}, {Path: "in2/report.pdf", RelDir: "."}},
+			outputDir: "out",
+			conflict:  "report.txt",
+		},
+		{
+			name:  "未指定输出目录时写在各自旁边",
+			items: []batchItem{{Path: "in1/report.pdf"}, {Path: "in2/report.pdf"}},
+		},
+		{
+			name:      "子目录不同",
+			items:     []batchItem{{Path: "in/a/report.pdf", RelDir: "a"}, {Path: "in/b/report.pdf", RelDir: "b"}},
+			outputDir: "out",
+		},
+		{
+			name:      "元数据文件与其他文件的输出重名",
+			items:     []batchItem{{Path: "in/report.pdf", RelDir: "."}, {Path: "in/report.meta.pdf", RelDir: "."}},
+			outputDir: "out",
+			opts:      ExtractOptions{Format: formatJSON, Meta: true},
+			conflict:  "report.meta.json",
+		},
+		{
+			name:      "不输出元数据时不冲突",
+			items:     []batchItem{{Path: "in/report.pdf", RelDir: "."}, {Path: "in/report.meta.pdf", RelDir: "."}},
+			outputDir: "out",
+			opts:      ExtractOptions{Format: formatJSON},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOutputCollisions(tt.items, tt.outputDir, tt.opts)
+			if tt.conflict == "" {
+				if err != nil {
+					t.Fatalf("不应冲突: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.conflict) {
+				t.Fatalf("期望 %s 冲突，得到 %v", tt.conflict, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// envPrefix 环境变量前缀
+const envPrefix = "PDF2TXT_"
+
+// Config Web服务的配置。优先级：命令行参数 > PDF2TXT_* 环境变量 > 配置文件 > 默认值
+type Config struct {
+	Host              string   `yaml:"host" toml:"host"`
+	Port              int      `yaml:"port" toml:"port"`
+	MultipartMemoryMB int64    `yaml:"multipart_memory_mb" toml:"multipart_memory_mb"`
+	OutputDir         string   `yaml:"output_dir" toml:"output_dir"`
+	OutputRoots       []string `yaml:"output_roots" toml:"output_roots"`
+	InputRoots        []string `yaml:"input_roots" toml:"input_roots"`
+	Backends          []string `yaml:"backends" toml:"backends"`
+	PdftotextLayout   bool     `yaml:"pdftotext_layout" toml:"pdftotext_layout"`
+	OCR               bool     `yaml:"ocr" toml:"ocr"`
+	OCRLanguages      string   `yaml:"ocr_languages" toml:"ocr_languages"`
+	Workers           int      `yaml:"workers" toml:"workers"`
+	FileTimeoutSec    int      `yaml:"file_timeout_seconds" toml:"file_timeout_seconds"`
+	MaxFileSizeMB     int64    `yaml:"max_file_size_mb" toml:"max_file_size_mb"`
+	MaxPages          int      `yaml:"max_pages" toml:"max_pages"`
+	MaxOutputMB       int64    `yaml:"max_output_mb" toml:"max_output_mb"`
+	MaxBatchFiles     int      `yaml:"max_batch_files" toml:"max_batch_files"`
+	Cache             bool     `yaml:"cache" toml:"cache"`
+	CacheDir          string   `yaml:"cache_dir" toml:"cache_dir"`
+	CacheMaxMB        int64    `yaml:"cache_max_mb" toml:"cache_max_mb"`
+	PasswordFile      string   `yaml:"password_file" toml:"password_file"`
+	TokenFile         string   `yaml:"token_file" toml:"token_file"`
+	BasicAuthFile     string   `yaml:"basic_auth_file" toml:"basic_auth_file"`
+}
+
+// defaultConfig 返回默认配置
+func defaultConfig() Config {
+	return Config{
+		Host:              "127.0.0.1",
+		Port:              8089,
+		MultipartMemoryMB: 100,
+		OutputDir:         "~/Desktop/PDF转换结果",
+		Backends:          append([]string(nil), defaultBackends...),
+		PdftotextLayout:   true,
+		OCR:               ocrEnabled,
+		OCRLanguages:      ocrLanguages,
+		Workers:           workers,
+		FileTimeoutSec:    int(fileTimeout / time.Second),
+		MaxFileSizeMB:     maxFileSize >> 20,
+		MaxPages:          maxPages,
+		MaxOutputMB:       maxOutputSize >> 20,
+		MaxBatchFiles:     maxBatchFiles,
+		Cache:             cacheEnabled,
+		CacheDir:          cacheDir,
+		CacheMaxMB:        cacheMaxSize >> 20,
+	}
+}
+
+// addr 监听地址
+func (c Config) addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// serveFlags serve 命令的参数，解析后只有显式指定的参数才会覆盖配置
+type serveFlags struct {
+	flags  *flag.FlagSet
+	config string
+	addr   string
+	roots  stringList
+	inputs stringList
+	values Config
+}
+
+// newServeFlags 注册 serve 命令的参数，帮助信息中显示默认配置
+func newServeFlags(name string) *serveFlags {
+	f := &serveFlags{flags: flag.NewFlagSet(name, flag.ContinueOnError), values: defaultConfig()}
+	flags, v := f.flags, &f.values
+
+	flags.StringVar(&f.config, "config", "", "配置文件（.yaml/.yml/.toml），也可通过 PDF2TXT_CONFIG 指定")
+	flags.StringVar(&f.addr, "addr", v.addr(), "监听地址，同时设置 host 和 port；默认只接受本机访问，对外开放时使用 :8089 并配置认证")
+	flags.StringVar(&v.Host, "host", v.Host, "监听的主机地址")
+	flags.IntVar(&v.Port, "port", v.Port, "监听端口")
+	flags.Int64Var(&v.MultipartMemoryMB, "multipart-memory", v.MultipartMemoryMB, "解析上传表单时使用的最大内存（MB），超出部分写入临时文件")
+	flags.StringVar(&v.OutputDir, "output-dir", v.OutputDir, "本地保存的默认输出目录")
+	flags.Var(&f.roots, "output-root", "允许本地保存的根目录，可重复指定（默认只允许 -output-dir）")
+	flags.Var(&f.inputs, "input-root", "允许直接转换服务器上文件的根目录，可重复指定（默认不允许）")
+	flags.Var((*commaList)(&v.Backends), "backends", "提取后端回退顺序，逗号分隔（可用: "+strings.Join(extractorNames(), ", ")+"）")
+	flags.BoolVar(&v.PdftotextLayout, "pdftotext-layout", v.PdftotextLayout, "调用 pdftotext 时使用 -layout 保留版面")
+	flags.BoolVar(&v.OCR, "ocr", v.OCR, "默认对没有文本层的页面执行OCR，请求可通过 ocr 字段覆盖")
+	flags.StringVar(&v.OCRLanguages, "ocr-lang", v.OCRLanguages, "默认的OCR识别语言，如 chi_sim+eng")
+	flags.IntVar(&v.Workers, "workers", v.Workers, "并发转换的文件数")
+	flags.IntVar(&v.FileTimeoutSec, "file-timeout", v.FileTimeoutSec, "单个文件的提取期限（秒），0 表示不限制")
+	flags.Int64Var(&v.MaxFileSizeMB, "max-file-size", v.MaxFileSizeMB, "单个PDF的最大大小（MB），0 表示不限制")
+	flags.IntVar(&v.MaxPages, "max-pages", v.MaxPages, "单个PDF的最大页数，0 表示不限制")
+	flags.Int64Var(&v.MaxOutputMB, "max-output", v.MaxOutputMB, "单个文件输出内容的最大大小（MB），0 表示不限制")
+	flags.IntVar(&v.MaxBatchFiles, "max-batch-files", v.MaxBatchFiles, "一次请求最多转换的PDF数，0 表示不限制")
+	flags.BoolVar(&v.Cache, "cache", v.Cache, "按PDF内容和提取参数缓存提取结果，相同的文件不再重复提取（缓存中保存文档的明文）")
+	flags.StringVar(&v.CacheDir, "cache-dir", v.CacheDir, "缓存目录")
+	flags.Int64Var(&v.CacheMaxMB, "cache-max", v.CacheMaxMB, "缓存的最大大小（MB），超出后删除最久未使用的条目，0 表示不限制")
+	flags.StringVar(&v.PasswordFile, "password-file", "", "候选密码文件，每行一个，对所有请求生效")
+	flags.StringVar(&v.TokenFile, "token-file", "", "API令牌文件，每行 \"<令牌> [zip|local|all]\"")
+	flags.StringVar(&v.BasicAuthFile, "basic-auth-file", "", "Basic认证文件，每行 \"<用户名>:<密码> [zip|local|all]\"")
+	return f
+}
+
+// load 依次合并默认值、配置文件、环境变量和显式指定的命令行参数
+func (f *serveFlags) load() (Config, error) {
+	cfg, err := loadConfig(f.config)
+	if err != nil {
+		return cfg, err
+	}
+
+	f.flags.Visit(func(fl *flag.Flag) {
+		if err == nil {
+			err = f.apply(&cfg, fl.Name)
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.validate()
+}
+
+// loadConfig 依次合并默认值、配置文件和环境变量，命令行参数由调用方覆盖。
+// path 为空时使用 PDF2TXT_CONFIG 指定的配置文件
+func loadConfig(path string) (Config, error) {
+	cfg := defaultConfig()
+	if path == "" {
+		path = os.Getenv(envPrefix + "CONFIG")
+	}
+	if path != "" {
+		if err := readConfigFile(path, &cfg); err != nil {
+			return cfg, fmt.Errorf("读取配置文件 %s 失败: %w", path, err)
+		}
+	}
+	if err := applyEnv(&cfg, os.Getenv); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// apply 把一个显式指定的命令行参数写入配置
+func (f *serveFlags) apply(cfg *Config, name string) error {
+	v := f.values
+	switch name {
+	case "addr":
+		host, port, err := splitAddr(f.addr)
+		if err != nil {
+			return err
+		}
+		cfg.Host, cfg.Port = host, port
+	case "host":
+		cfg.Host = v.Host
+	case "port":
+		cfg.Port = v.Port
+	case "multipart-memory":
+		cfg.MultipartMemoryMB = v.MultipartMemoryMB
+	case "output-dir":
+		cfg.OutputDir = v.OutputDir
+	case "output-root":
+		cfg.OutputRoots = f.roots
+	case "input-root":
+		cfg.InputRoots = f.inputs
+	case "backends":
+		cfg.Backends = v.Backends
+	case "pdftotext-layout":
+		cfg.PdftotextLayout = v.PdftotextLayout
+	case "ocr":
+		cfg.OCR = v.OCR
+	case "ocr-lang":
+		cfg.OCRLanguages = v.OCRLanguages
+	case "workers":
+		cfg.Workers = v.Workers
+	case "file-timeout":
+		cfg.FileTimeoutSec = v.FileTimeoutSec
+	case "max-file-size":
+		cfg.MaxFileSizeMB = v.MaxFileSizeMB
+	case "max-pages":
+		cfg.MaxPages = v.MaxPages
+	case "max-output":
+		cfg.MaxOutputMB = v.MaxOutputMB
+	case "max-batch-files":
+		cfg.MaxBatchFiles = v.MaxBatchFiles
+	case "cache":
+		cfg.Cache = v.Cache
+	case "cache-dir":
+		cfg.CacheDir = v.CacheDir
+	case "cache-max":
+		cfg.CacheMaxMB = v.CacheMaxMB
+	case "password-file":
+		cfg.PasswordFile = v.PasswordFile
+	case "token-file":
+		cfg.TokenFile = v.TokenFile
+	case "basic-auth-file":
+		cfg.BasicAuthFile = v.BasicAuthFile
+	}
+	return nil
+}
+
+// splitAddr 把 "host:port" 拆分为主机和端口
+func splitAddr(addr string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, fmt.Errorf("无效的监听地址 %s: %w", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("无效的端口 %s", portStr)
+	}
+	return host, port, nil
+}
+
+// readConfigFile 按扩展名读取YAML或TOML配置文件，未知的配置项视为错误
+func readConfigFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.UnmarshalStrict(data, cfg)
+	case ".toml":
+		meta, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return err
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("未知的配置项: %v", undecoded)
+		}
+		return nil
+	}
+	return fmt.Errorf("不支持的配置文件格式（可用: .yaml, .yml, .toml）")
+}
+
+// applyEnv 用 PDF2TXT_* 环境变量覆盖配置；列表用逗号分隔
+func applyEnv(cfg *Config, getenv func(string) string) error {
+	strs := map[string]*string{
+		"HOST":            &cfg.Host,
+		"OUTPUT_DIR":      &cfg.OutputDir,
+		"PASSWORD_FILE":   &cfg.PasswordFile,
+		"TOKEN_FILE":      &cfg.TokenFile,
+		"BASIC_AUTH_FILE": &cfg.BasicAuthFile,
+		"OCR_LANGUAGES":   &cfg.OCRLanguages,
+		"CACHE_DIR":       &cfg.CacheDir,
+	}
+	for name, p := range strs {
+		if v := getenv(envPrefix + name); v != "" {
+			*p = v
+		}
+	}
+
+	lists := map[string]*[]string{
+		"OUTPUT_ROOTS": &cfg.OutputRoots,
+		"INPUT_ROOTS":  &cfg.InputRoots,
+		"BACKENDS":     &cfg.Backends,
+	}
+	for name, p := range lists {
+		if v := getenv(envPrefix + name); v != "" {
+			*p = splitList(v)
+		}
+	}
+
+	ints := map[string]*int{
+		"PORT":                 &cfg.Port,
+		"WORKERS":              &cfg.Workers,
+		"FILE_TIMEOUT_SECONDS": &cfg.FileTimeoutSec,
+		"MAX_PAGES":            &cfg.MaxPages,
+		"MAX_BATCH_FILES":      &cfg.MaxBatchFiles,
+	}
+	for name, p := range ints {
+		if v := getenv(envPrefix + name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("环境变量 %s%s 必须是整数: %s", envPrefix, name, v)
+			}
+			*p = n
+		}
+	}
+
+	int64s := map[string]*int64{
+		"MULTIPART_MEMORY_MB": &cfg.MultipartMemoryMB,
+		"MAX_FILE_SIZE_MB":    &cfg.MaxFileSizeMB,
+		"MAX_OUTPUT_MB":       &cfg.MaxOutputMB,
+		"CACHE_MAX_MB":        &cfg.CacheMaxMB,
+	}
+	for name, p := range int64s {
+		if v := getenv(envPrefix + name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return fmt.Errorf("环境变量 %s%s 必须是整数: %s", envPrefix, name, v)
+			}
+			*p = n
+		}
+	}
+
+	bools := map[string]*bool{
+		"PDFTOTEXT_LAYOUT": &cfg.PdftotextLayout,
+		"OCR":              &cfg.OCR,
+		"CACHE":            &cfg.Cache,
+	}
+	for name, p := range bools {
+		if v := getenv(envPrefix + name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("环境变量 %s%s 必须是布尔值: %s", envPrefix, name, v)
+			}
+			*p = b
+		}
+	}
+	return nil
+}
+
+// validate 检查配置的取值范围
+func (c Config) validate() error {
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("无效的端口: %d", c.Port)
+	}
+	if c.MultipartMemoryMB < 1 {
+		return fmt.Errorf("multipart_memory_mb 必须大于0")
+	}
+	if c.Workers < 1 {
+		return fmt.Errorf("workers 必须大于0")
+	}
+	if c.FileTimeoutSec < 0 {
+		return fmt.Errorf("file_timeout_seconds 不能小于0")
+	}
+	if c.MaxFileSizeMB < 0 || c.MaxPages < 0 || c.MaxOutputMB < 0 || c.MaxBatchFiles < 0 {
+		return fmt.Errorf("max_file_size_mb、max_pages、max_output_mb、max_batch_files 不能小于0")
+	}
+	if c.CacheMaxMB < 0 {
+		return fmt.Errorf("cache_max_mb 不能小于0")
+	}
+	if c.OutputDir == "" {
+		return fmt.Errorf("output_dir 不能为空")
+	}
+	if c.Cache && c.CacheDir == "" {
+		return fmt.Errorf("启用缓存时 cache_dir 不能为空")
+	}
+	if c.OCRLanguages == "" {
+		return fmt.Errorf("ocr_languages 不能为空")
+	}
+	if _, err := parseBackends(strings.Join(c.Backends, ",")); err != nil {
+		return err
+	}
+	return nil
+}
+
+// apply 把配置写入各模块使用的全局设置
+func (c Config) apply() error {
+	workers = c.Workers
+	fileTimeout = time.Duration(c.FileTimeoutSec) * time.Second
+	maxFileSize, maxPages = c.MaxFileSizeMB<<20, c.MaxPages
+	maxOutputSize, maxBatchFiles = c.MaxOutputMB<<20, c.MaxBatchFiles
+	multipartMemory = c.MultipartMemoryMB << 20
+	pdftotextLayout = c.PdftotextLayout
+	ocrEnabled, ocrLanguages = c.OCR, c.OCRLanguages
+	cacheEnabled, cacheDir, cacheMaxSize = c.Cache, expandHome(c.CacheDir), c.CacheMaxMB<<20
+
+	chain, err := parseBackends(strings.Join(c.Backends, ","))
+	if err != nil {
+		return err
+	}
+	defaultBackends = chain
+
+	outputDir, err := filepath.Abs(expandHome(c.OutputDir))
+	if err != nil {
+		return fmt.Errorf("无效的输出目录: %w", err)
+	}
+	defaultOutputDir = outputDir
+	if outputRoots, err = parseOutputRoots(c.OutputRoots); err != nil {
+		return fmt.Errorf("无效的输出根目录: %w", err)
+	}
+	if _, err := resolveOutputTarget(""); err != nil {
+		return fmt.Errorf("默认输出目录必须位于 output_roots 内: %w", err)
+	}
+	if inputRoots, err = parseOutputRoots(c.InputRoots); err != nil {
+		return fmt.Errorf("无效的输入根目录: %w", err)
+	}
+
+	if c.PasswordFile != "" {
+		if defaultPasswords, err = readPasswordFile(c.PasswordFile); err != nil {
+			return fmt.Errorf("读取密码文件失败: %w", err)
+		}
+	}
+	if c.TokenFile != "" {
+		if apiTokens, err = readTokenFile(c.TokenFile); err != nil {
+			return fmt.Errorf("读取令牌文件失败: %w", err)
+		}
+	}
+	if c.BasicAuthFile != "" {
+		if basicUsers, err = readBasicAuthFile(c.BasicAuthFile); err != nil {
+			return fmt.Errorf("读取Basic认证文件失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// splitList 拆分逗号分隔的列表并去掉空白
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// commaList 逗号分隔的命令行参数
+type commaList []string
+
+func (l *commaList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *commaList) Set(value string) error {
+	*l = splitList(value)
+	return nil
+}
+
+// runConfig 处理 config 子命令
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "用法: pdf2txt config print [serve 的选项]")
+		return 2
+	}
+
+	f := newServeFlags("config print")
+	if err := f.flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	cfg, err := f.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Stdout.Write(data)
+	return 0
+}
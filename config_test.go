@@ -0,0 +1,166 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestServeFlagsLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "pdf2txt.yaml")
+	os.WriteFile(yamlFile, []byte("port: 9000\nworkers: 3\nmax_pages: 50\nbackends: [pdftotext]\n"), 0o600)
+	tomlFile := filepath.Join(dir, "pdf2txt.toml")
+	os.WriteFile(tomlFile, []byte("port = 9100\nworkers = 5\n"), 0o600)
+
+	def := defaultConfig()
+	tests := []struct {
+		name        string
+		args        []string
+		env         map[string]string
+		wantPort    int
+		wantWorkers int
+		wantPages   int
+		wantHost    string
+	}{
+		{name: "默认值", wantPort: def.Port, wantWorkers: def.Workers, wantPages: def.MaxPages, wantHost: def.Host},
+		{name: "配置文件覆盖默认值", args: []string{"-config", yamlFile}, wantPort: 9000, wantWorkers: 3, wantPages: 50, wantHost: def.Host},
+		{name: "TOML配置文件", args: []string{"-config", tomlFile}, wantPort: 9100, wantWorkers: 5, wantPages: def.MaxPages, wantHost: def.Host},
+		{name: "PDF2TXT_CONFIG", env: map[string]string{"PDF2TXT_CONFIG": yamlFile}, wantPort: 9000, wantWorkers: 3, wantPages: 50, wantHost: def.Host},
+		{name: "-config优先于PDF2TXT_CONFIG", args: []string{"-config", tomlFile}, env: map[string]string{"PDF2TXT_CONFIG": yamlFile}, wantPort: 9100, wantWorkers: 5, wantPages: def.MaxPages, wantHost: def.Host},
+		{
+			name:     "环境变量覆盖配置文件",
+			args:     []string{"-config", yamlFile},
+			env:      map[string]string{"PDF2TXT_PORT": "9200", "PDF2TXT_HOST": "0.0.0.0"},
+			wantPort: 9200, wantWorkers: 3, wantPages: 50, wantHost: "0.0.0.0",
+		},
+		{
+			name:     "命令行参数覆盖环境变量",
+			args:     []string{"-config", yamlFile, "-port", "9300", "-workers", "7"},
+			env:      map[string]string{"PDF2TXT_PORT": "9200", "PDF2TXT_WORKERS": "4"},
+			wantPort: 9300, wantWorkers: 7, wantPages: 50, wantHost: def.Host,
+		},
+		{
+			name:     "-addr同时设置主机和端口",
+			args:     []string{"-addr", ":9400"},
+			env:      map[string]string{"PDF2TXT_HOST": "10.0.0.1"},
+			wantPort: 9400, wantWorkers: def.Workers, wantPages: def.MaxPages, wantHost: "",
+		},
+		{
+			name:     "未显式指定的参数不覆盖配置",
+			args:     []string{"-config", yamlFile, "-max-pages", "0"},
+			env:      map[string]string{"PDF2TXT_WORKERS": "4"},
+			wantPort: 9000, wantWorkers: 4, wantPages: 0, wantHost: def.Host,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"CONFIG", "HOST", "PORT", "WORKERS"} {
+				t.Setenv(envPrefix+name, tt.env[envPrefix+name])
+			}
+
+			f := newServeFlags("serve")
+			if err := f.flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := f.load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Port != tt.wantPort || cfg.Workers != tt.wantWorkers || cfg.MaxPages != tt.wantPages || cfg.Host != tt.wantHost {
+				t.Errorf("host=%q port=%d workers=%d max_pages=%d，want host=%q port=%d workers=%d max_pages=%d",
+					cfg.Host, cfg.Port, cfg.Workers, cfg.MaxPages, tt.wantHost, tt.wantPort, tt.wantWorkers, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"PDF2TXT_OUTPUT_ROOTS":     "/a, /b ,",
+		"PDF2TXT_BACKENDS":         "pdftotext,unipdf",
+		"PDF2TXT_MAX_FILE_SIZE_MB": "7",
+		"PDF2TXT_OCR":              "true",
+		"PDF2TXT_CACHE_DIR":        "/tmp/c",
+	}
+	cfg := defaultConfig()
+	if err := applyEnv(&cfg, func(k string) string { return env[k] }); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.OutputRoots, []string{"/a", "/b"}) {
+		t.Errorf("OutputRoots = %q", cfg.OutputRoots)
+	}
+	if !reflect.DeepEqual(cfg.Backends, []string{"pdftotext", "unipdf"}) {
+		t.Errorf("Backends = %q", cfg.Backends)
+	}
+	if cfg.MaxFileSizeMB != 7 || !cfg.OCR || cfg.CacheDir != "/tmp/c" {
+		t.Errorf("max_file_size_mb=%d ocr=%v cache_dir=%q", cfg.MaxFileSizeMB, cfg.OCR, cfg.CacheDir)
+	}
+
+	for name, value := range map[string]string{
+		"PDF2TXT_PORT":          "http",
+		"PDF2TXT_MAX_OUTPUT_MB": "1.5",
+		"PDF2TXT_CACHE":         "sometimes",
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := defaultConfig()
+			err := applyEnv(&cfg, func(k string) string {
+				if k == name {
+					return value
+				}
+				return ""
+			})
+			if err == nil {
+				t.Errorf("%s=%s 应返回错误", name, value)
+			}
+		})
+	}
+}
+
+func TestReadConfigFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, file, content string
+	}{
+		{name: "YAML未知配置项", file: "a.yaml", content: "prot: 80\n"},
+		{name: "TOML未知配置项", file: "a.toml", content: "prot = 80\n"},
+		{name: "类型错误", file: "b.yml", content: "port: abc\n"},
+		{name: "不支持的格式", file: "a.json", content: "{}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			os.WriteFile(path, []byte(tt.content), 0o600)
+			cfg := defaultConfig()
+			if err := readConfigFile(path, &cfg); err == nil {
+				t.Errorf("readConfigFile(%s) 应返回错误", tt.file)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{name: "默认配置", modify: func(c *Config) {}},
+		{name: "端口越界", modify: func(c *Config) { c.Port = 70000 }, wantErr: true},
+		{name: "workers为0", modify: func(c *Config) { c.Workers = 0 }, wantErr: true},
+		{name: "负的限制", modify: func(c *Config) { c.MaxPages = -1 }, wantErr: true},
+		{name: "启用缓存但没有目录", modify: func(c *Config) { c.Cache, c.CacheDir = true, "" }, wantErr: true},
+		{name: "未知后端", modify: func(c *Config) { c.Backends = []string{"acrobat"} }, wantErr: true},
+		{name: "限制为0表示不限制", modify: func(c *Config) { c.MaxPages, c.MaxFileSizeMB = 0, 0 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			tt.modify(&cfg)
+			if err := cfg.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() = %v，wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
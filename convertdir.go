@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// inputRoots 允许直接转换服务器上文件的根目录，由 input_roots 配置；未配置时不提供该功能
+var inputRoots []string
+
+// 转换服务器上的目录时结果的保存位置
+const (
+	placeBeside = "beside" // 写在源文件旁边
+	placeMirror = "mirror" // 在输出目录下还原源目录的结构
+)
+
+// resolveInputDir 把客户端指定的输入目录解析到允许的根目录内。
+// 返回的 outputTarget 指向输入目录本身，结果写在源文件旁边时直接使用。
+func resolveInputDir(dir string) (outputTarget, error) {
+	dir = expandHome(strings.TrimSpace(dir))
+	if dir == "" {
+		return outputTarget{}, newConvertError(CodeUnsafePath, "没有指定输入目录")
+	}
+	if !filepath.IsAbs(dir) {
+		return outputTarget{}, newConvertError(CodeUnsafePath, "输入目录 %s 必须是绝对路径", dir)
+	}
+	dir = filepath.Clean(dir)
+
+	for _, root := range inputRoots {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+		if rel == "." {
+			rel = ""
+		}
+		target := outputTarget{root: root, dir: filepath.ToSlash(rel)}
+		if err := target.checkSymlinks(target.dir); err != nil {
+			return target, err
+		}
+		info, err := os.Stat(dir)
+		if err != nil {
+			return target, newConvertError(CodeIO, "读取输入目录失败: %w", err)
+		}
+		if !info.IsDir() {
+			return target, newConvertError(CodeIO, "%s 不是目录", dir)
+		}
+		return target, nil
+	}
+	return outputTarget{}, newConvertError(CodeUnsafePath, "输入目录 %s 不在允许的范围内（%s）", dir, strings.Join(inputRoots, ", "))
+}
+
+// inputDirErrorStatus 输入目录不存在时返回404，其他错误同 pathErrorStatus
+func inputDirErrorStatus(err error) int {
+	if errors.Is(err, fs.ErrNotExist) {
+		return http.StatusNotFound
+	}
+	return pathErrorStatus(err)
+}
+
+// convertDirHandler 创建转换服务器上某个目录的异步任务，不需要上传文件。
+// 结果写在源文件旁边，或按源目录的结构保存到输出目录，进度通过任务API查询。
+func convertDirHandler(w http.ResponseWriter, r *http.Request) {
+	// 会读写服务器上的文件，与本地保存使用同一权限
+	if !hasPermission(r, permLocal) {
+		writeJSONError(w, permissionError(permLocal), http.StatusForbidden)
+		return
+	}
+	if len(inputRoots) == 0 {
+		writeJSONError(w, "服务器未配置 input_roots，不能直接转换服务器上的目录", http.StatusForbidden)
+		return
+	}
+
+	// 同时接受multipart和urlencoded表单
+	if err := r.ParseMultipartForm(multipartMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		writeJSONError(w, fmt.Sprintf("解析表单失败: %v", err), http.StatusBadRequest)
+		return
+	}
+	form := r.MultipartForm
+	if form == nil {
+		form = &multipart.Form{Value: r.PostForm}
+	}
+
+	opts, err := extractOptionsFromForm(form)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	place := formValue(form, "output")
+	if place == "" {
+		place = placeMirror
+	}
+	if place != placeBeside && place != placeMirror {
+		writeJSONError(w, fmt.Sprintf("未知的保存位置: %s", place), http.StatusBadRequest)
+		return
+	}
+
+	existing, err := parseExistingPolicy(formValue(form, "existing"))
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	recursive, err := formBool(form, "recursive", true)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resume, err := formBool(form, "resume", false)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	retryFailed, err := formBool(form, "retryFailed", false)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	source, err := resolveInputDir(formValue(form, "inputDir"))
+	if err != nil {
+		writeJSONError(w, err.Error(), inputDirErrorStatus(err))
+		return
+	}
+	inputDir := source.path()
+
+	includes := form.Value["include"]
+	if len(includes) == 0 {
+		includes = []string{"*.pdf"}
+	}
+	items, err := collectPDFs([]string{inputDir}, recursive, includes, form.Value["exclude"])
+	if err != nil {
+		writeJSONError(w, fmt.Sprintf("扫描输入目录失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	// 跳过符号链接等非普通文件，避免读取根目录之外的文件
+	regular := items[:0]
+	var infos []fs.FileInfo
+	for _, item := range items {
+		info, err := os.Lstat(item.Path)
+		if err != nil || !info.Mode().IsRegular() {
+			log.Printf("跳过非普通文件: %s\n", item.Path)
+			continue
+		}
+		regular = append(regular, item)
+		infos = append(infos, info)
+	}
+	items = regular
+
+	if len(items) == 0 {
+		writeJSONError(w, "输入目录中没有PDF文件", http.StatusBadRequest)
+		return
+	}
+	if err := checkBatchSize(len(items)); err != nil {
+		writeJSONError(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	j := &job{
+		id:          newJobID(),
+		mode:        modeLocal,
+		status:      statusPending,
+		created:     time.Now(),
+		inputDir:    inputDir,
+		existing:    existing,
+		retryFailed: retryFailed,
+		opts:        opts,
+		subscribers: make(map[chan jobEvent]struct{}),
+	}
+
+	// 与上传文件夹相同，输出目录下保留输入目录的名称
+	top := filepath.Base(inputDir)
+	switch place {
+	case placeBeside:
+		j.target = source
+	case placeMirror:
+		if j.target, err = resolveOutputTarget(formValue(form, "outputDir")); err != nil {
+			writeJSONError(w, err.Error(), pathErrorStatus(err))
+			return
+		}
+		j.target.dir = path.Join(j.target.dir, top)
+		if err := j.target.prepare(); err != nil {
+			writeJSONError(w, err.Error(), pathErrorStatus(err))
+			return
+		}
+	}
+	j.outputPath = j.target.path()
+
+	filenames := make([]string, len(items))
+	relPaths := make([]string, len(items))
+	for i, item := range items {
+		rel, err := filepath.Rel(inputDir, item.Path)
+		if err != nil {
+			writeJSONError(w, fmt.Sprintf("解析文件路径失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+		f := jobFile{
+			Name:    filepath.Base(item.Path),
+			Path:    path.Join(top, filepath.ToSlash(rel)),
+			Status:  statusPending,
+			spool:   item.Path,
+			modTime: infos[i].ModTime(),
+			size:    infos[i].Size(),
+		}
+		f.Input = item.Path
+		j.files = append(j.files, f)
+		filenames[i], relPaths[i] = f.Name, f.Path
+	}
+	if err := j.target.checkOutputs(filenames, relPaths, opts.Format); err != nil {
+		writeJSONError(w, err.Error(), pathErrorStatus(err))
+		return
+	}
+
+	// 检查点保存在输出目录下，服务中途停止后可以用 resume 继续
+	if j.journal, err = j.target.openJournal(newJournalHeader(j.outputPath, opts), resume || retryFailed); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errJournalMismatch) {
+			status = http.StatusConflict
+		}
+		writeJSONError(w, err.Error(), status)
+		return
+	}
+
+	startJob(w, j)
+}
+
+// formBool 解析布尔类型的表单字段，未提供时返回默认值
+func formBool(form *multipart.Form, name string, def bool) (bool, error) {
+	v := formValue(form, name)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("无效的 %s 参数: %s", name, v)
+	}
+	return b, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveInputDir(t *testing.T) {
+	root, outside := setupRoot(t)
+	saved := inputRoots
+	t.Cleanup(func() { inputRoots = saved })
+	inputRoots = []string{root}
+
+	tests := []struct {
+		dir     string
+		wantErr bool
+	}{
+		{root, false},
+		{filepath.Join(root, "sub"), false},
+		{filepath.Join(root, "inner"), false},
+		{filepath.Join(root, "link"), true},
+		{filepath.Join(root, "sub", "..", "..", "outside"), true},
+		{outside, true},
+		{"sub", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			_, err := resolveInputDir(tt.dir)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("resolveInputDir(%q) = %v，wantErr %v", tt.dir, err, tt.wantErr)
+			}
+			if tt.wantErr && errorCode(err) != CodeUnsafePath {
+				t.Fatalf("错误码 = %s，want %s", errorCode(err), CodeUnsafePath)
+			}
+		})
+	}
+}
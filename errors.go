@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorCode 转换失败的稳定错误码，通过API返回给调用方
+type ErrorCode string
+
+const (
+	CodeEncrypted        ErrorCode = "encrypted"          // 文件已加密，需要密码
+	CodeCorrupted        ErrorCode = "corrupted"          // 文件损坏或不是PDF
+	CodeUnsupportedFont  ErrorCode = "unsupported_font"   // 字体不受后端支持
+	CodeBackendMissing   ErrorCode = "backend_missing"    // 后端依赖的外部命令未安装
+	CodeTimeout          ErrorCode = "timeout"            // 转换超时
+	CodeCanceled         ErrorCode = "canceled"           // 转换被取消
+	CodeEmptyText        ErrorCode = "empty_text"         // 没有提取到任何文本，可能是扫描件
+	CodeInvalidPageRange ErrorCode = "invalid_page_range" // 页码范围超出文档页数
+	CodeIO               ErrorCode = "io_error"           // 读写文件失败
+	CodeUnsafePath       ErrorCode = "unsafe_path"        // 路径离开了允许的输出目录
+	CodeLimitExceeded    ErrorCode = "limit_exceeded"     // 文件超出资源限制（大小、页数、输出大小、文件数）
+	CodeUnknown          ErrorCode = "conversion_failed"
+)
+
+// ConvertError 带错误码的转换错误
+type ConvertError struct {
+	Code    ErrorCode
+	Backend string // 产生错误的后端，与具体后端无关时为空
+	Err     error
+}
+
+func (e *ConvertError) Error() string {
+	if e.Backend != "" {
+		return fmt.Sprintf("%s: %v", e.Backend, e.Err)
+	}
+	return e.Err.Error()
+}
+
+func (e *ConvertError) Unwrap() error {
+	return e.Err
+}
+
+// newConvertError 创建带错误码的错误
+func newConvertError(code ErrorCode, format string, args ...interface{}) *ConvertError {
+	return &ConvertError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// chainError 所有后端都失败时的错误，保留每个后端的原始错误
+type chainError struct {
+	errs []error
+}
+
+func (e *chainError) Error() string {
+	parts := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		parts[i] = err.Error()
+	}
+	return "所有转换方法都失败了: " + strings.Join(parts, "; ")
+}
+
+func (e *chainError) Unwrap() []error {
+	return e.errs
+}
+
+// code 选出最能说明失败原因的错误码：
+// 超时、取消和超出限制优先；“后端未安装”只有在所有后端都缺失时才返回。
+func (e *chainError) code() ErrorCode {
+	code := CodeBackendMissing
+	found := false
+	for _, err := range e.errs {
+		c := errorCode(err)
+		switch {
+		case c == CodeTimeout || c == CodeCanceled || c == CodeLimitExceeded:
+			return c
+		case c != CodeBackendMissing && !found:
+			code = c
+			found = true
+		}
+	}
+	return code
+}
+
+// errorCode 返回错误对应的错误码
+func errorCode(err error) ErrorCode {
+	var chain *chainError
+	if errors.As(err, &chain) {
+		return chain.code()
+	}
+	var ce *ConvertError
+	if errors.As(err, &ce) {
+		return ce.Code
+	}
+	return classifyError(err)
+}
+
+// classifyError 根据错误内容推断错误码，用于第三方库返回的未分类错误
+func classifyError(err error) ErrorCode {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeTimeout
+	case errors.Is(err, context.Canceled):
+		return CodeCanceled
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "decrypt") || strings.Contains(msg, "password"):
+		return CodeEncrypted
+	case strings.Contains(msg, "font"):
+		return CodeUnsupportedFont
+	}
+	return CodeUnknown
+}
+
+// wrapBackendError 为后端返回的错误补充错误码和后端名称
+func wrapBackendError(backend string, err error) error {
+	if ce, ok := err.(*ConvertError); ok {
+		if ce.Backend == "" {
+			ce.Backend = backend
+		}
+		return ce
+	}
+	return &ConvertError{Code: classifyError(err), Backend: backend, Err: err}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 输出文件已存在时的处理策略
+const (
+	existingOverwrite = "overwrite" // 重新转换并覆盖
+	existingSkip      = "skip"      // 跳过，保留已有的输出
+	existingNewer     = "newer"     // 只在PDF比已有输出新时重新转换
+)
+
+// parseExistingPolicy 校验已有输出的处理策略，空字符串表示覆盖
+func parseExistingPolicy(s string) (string, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "":
+		return existingOverwrite, nil
+	case existingOverwrite, existingSkip, existingNewer:
+		return s, nil
+	}
+	return "", fmt.Errorf("未知的已有输出处理策略: %s（可用: overwrite, skip, newer）", s)
+}
+
+// skipExisting 判断是否保留已有的输出。existing 为已有输出的信息，不存在时为nil；
+// PDF修改时间未知时 newer 按覆盖处理
+func skipExisting(policy string, existing fs.FileInfo, pdfModTime time.Time) bool {
+	if existing == nil || !existing.Mode().IsRegular() {
+		return false
+	}
+	switch policy {
+	case existingSkip:
+		return true
+	case existingNewer:
+		return !pdfModTime.IsZero() && !pdfModTime.After(existing.ModTime())
+	}
+	return false
+}
+
+// skipExistingFile 命令行转换时按策略检查已有的输出文件
+func skipExistingFile(policy, pdfPath, outputPath string) bool {
+	if policy == existingOverwrite {
+		return false
+	}
+	existing, err := os.Stat(outputPath)
+	if err != nil {
+		return false
+	}
+	var modTime time.Time
+	if info, err := os.Stat(pdfPath); err == nil {
+		modTime = info.ModTime()
+	}
+	return skipExisting(policy, existing, modTime)
+}
+
+// parseModTimes 解析浏览器上传的文件修改时间（毫秒时间戳），无法解析的留空
+func parseModTimes(values []string) []time.Time {
+	times := make([]time.Time, len(values))
+	for i, v := range values {
+		if ms, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil && ms > 0 {
+			times[i] = time.UnixMilli(ms)
+		}
+	}
+	return times
+}
+
+// writeFileAtomic 先写入同一目录下的临时文件再重命名，中途崩溃不会留下不完整的文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	// 重命名前落盘，断电后不会出现重命名已生效而内容为空的文件
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
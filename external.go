@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// commandWaitDelay 上下文取消后等待外部命令退出并关闭输出管道的最长时间
+const commandWaitDelay = 5 * time.Second
+
+// externalCommand 创建随上下文取消而终止的外部命令。
+// 命令派生的子进程继续占用输出管道时，WaitDelay 保证调用方不会一直阻塞。
+func externalCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
+
+// writeTempPDF 把PDF数据写入临时文件供外部命令读取，调用方负责删除
+func writeTempPDF(data []byte) (string, error) {
+	tmpFile, err := os.CreateTemp("", "pdf2txt-*.pdf")
+	if err != nil {
+		return "", newConvertError(CodeIO, "创建临时文件失败: %w", err)
+	}
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return "", newConvertError(CodeIO, "写入临时文件失败: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpFile.Name())
+		return "", newConvertError(CodeIO, "写入临时文件失败: %w", err)
+	}
+	return tmpFile.Name(), nil
+}
+
+// commandError 根据退出状态和错误输出为外部命令的失败分类
+func commandError(ctx context.Context, name string, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return newConvertError(classifyError(ctxErr), "%s执行失败: %w", name, ctxErr)
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return newConvertError(CodeUnknown, "%s执行失败: %w", name, err)
+	}
+
+	stderr := strings.TrimSpace(string(exitErr.Stderr))
+	code := CodeUnknown
+	switch {
+	case strings.Contains(stderr, "Incorrect password") || strings.Contains(stderr, "cannot authenticate password"):
+		code = CodeEncrypted
+	case strings.Contains(stderr, "Wrong page range") || strings.Contains(stderr, "page out of range"):
+		code = CodeInvalidPageRange
+	case strings.Contains(stderr, "Syntax Error") || strings.Contains(stderr, "May not be a PDF file") ||
+		strings.Contains(stderr, "cannot recognize version marker"):
+		code = CodeCorrupted
+	case strings.Contains(stderr, "Failed loading language"):
+		code = CodeBackendMissing
+	}
+	if stderr == "" {
+		return newConvertError(code, "%s执行失败: %w", name, err)
+	}
+	return newConvertError(code, "%s执行失败: %w: %s", name, err, stderr)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 服务端的OCR默认设置，请求可以覆盖
+var (
+	// ocrEnabled 是否对没有文本层的页面执行OCR，由 ocr 配置
+	ocrEnabled = false
+	// ocrLanguages tesseract 的识别语言，由 ocr_languages 配置
+	ocrLanguages = "chi_sim+eng"
+)
+
+// ocrDPI 渲染页面图片的分辨率
+const ocrDPI = 300
+
+func init() {
+	registerExtractor(ocrExtractor{})
+}
+
+// ocrExtractor 把页面渲染为图片后用本机的 tesseract 识别文字，适用于扫描件
+type ocrExtractor struct{}
+
+func (ocrExtractor) Name() string { return "ocr" }
+
+func (ocrExtractor) Capabilities() Capabilities {
+	return Capabilities{External: true}
+}
+
+func (ocrExtractor) Extract(ctx context.Context, data []byte, opts ExtractOptions) (*ExtractResult, error) {
+	return runOCR(ctx, data, opts.PageRange.spans(), opts)
+}
+
+// rasterizer 把PDF页面渲染为PNG图片的外部命令，输出文件名为 <prefix>-<页码>.png
+type rasterizer struct {
+	name string
+	args func(path, prefix, password string, span pageSpan) []string
+}
+
+// rasterizers 按优先顺序排列的渲染命令
+var rasterizers = []rasterizer{
+	{
+		name: "pdftoppm",
+		args: func(path, prefix, password string, span pageSpan) []string {
+			args := []string{"-r", strconv.Itoa(ocrDPI), "-gray", "-png", "-f", strconv.Itoa(span.From)}
+			if span.To > 0 {
+				args = append(args, "-l", strconv.Itoa(span.To))
+			}
+			if password != "" {
+				args = append(args, "-opw", password, "-upw", password)
+			}
+			return append(args, path, prefix)
+		},
+	},
+	{
+		name: "mutool",
+		args: func(path, prefix, password string, span pageSpan) []string {
+			args := []string{"draw", "-q", "-r", strconv.Itoa(ocrDPI), "-c", "gray", "-o", prefix + "-%d.png"}
+			if password != "" {
+				args = append(args, "-p", password)
+			}
+			pages := strconv.Itoa(span.From) + "-N"
+			if span.To > 0 {
+				pages = strconv.Itoa(span.From) + "-" + strconv.Itoa(span.To)
+			}
+			return append(args, path, pages)
+		},
+	},
+}
+
+// findRasterizer 返回第一个已安装的渲染命令
+func findRasterizer() (rasterizer, error) {
+	for _, r := range rasterizers {
+		if _, err := exec.LookPath(r.name); err == nil {
+			return r, nil
+		}
+	}
+	return rasterizer{}, newConvertError(CodeBackendMissing, "OCR需要 pdftoppm（poppler-utils）或 mutool（mupdf-tools）")
+}
+
+// runOCR 渲染并识别指定区间的页面，识别结果按页码排序并标记为OCR；
+// 个别页面识别失败时记录在 Failed 中，其余页面照常返回
+func runOCR(ctx context.Context, data []byte, spans []pageSpan, opts ExtractOptions) (*ExtractResult, error) {
+	if _, err := exec.LookPath("tesseract"); err != nil {
+		return nil, newConvertError(CodeBackendMissing, "tesseract命令不可用，请安装tesseract-ocr")
+	}
+	raster, err := findRasterizer()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpPath, err := writeTempPDF(data)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpPath)
+
+	languages := opts.OCRLanguages
+	if languages == "" {
+		languages = ocrLanguages
+	}
+
+	passwords := newPasswordTrier(opts)
+	result := &ExtractResult{}
+	for _, span := range spans {
+		pages, failed, err := ocrSpan(ctx, raster, tmpPath, span, languages, passwords)
+		if err != nil {
+			if spanPastEnd(err, len(result.Pages)+len(result.Failed)) {
+				break
+			}
+			return nil, err
+		}
+		result.Pages = append(result.Pages, pages...)
+		result.Failed = append(result.Failed, failed...)
+	}
+	return result, nil
+}
+
+// ocrSpan 把一个区间的页面渲染到临时目录，逐页识别后删除图片。
+// 渲染失败或被中断时返回错误；单页识别失败时记录该页，继续识别其余页面
+func ocrSpan(ctx context.Context, raster rasterizer, path string, span pageSpan, languages string, passwords *passwordTrier) ([]Page, []PageError, error) {
+	dir, err := os.MkdirTemp("", "pdf2txt-ocr-*")
+	if err != nil {
+		return nil, nil, newConvertError(CodeIO, "创建临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	prefix := filepath.Join(dir, "page")
+	span = span.capped()
+	err = passwords.try(func(password string) error {
+		cmd := externalCommand(ctx, raster.name, raster.args(path, prefix, password, span)...)
+		if _, err := cmd.Output(); err != nil {
+			return commandError(ctx, raster.name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	images, err := renderedPages(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pages := make([]Page, 0, len(images))
+	var failed []PageError
+	for _, img := range images {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, newConvertError(classifyError(err), "OCR被中断: %w", err)
+		}
+
+		output, err := externalCommand(ctx, "tesseract", img.path, "stdout", "-l", languages).Output()
+		os.Remove(img.path)
+		if err != nil {
+			err = commandError(ctx, "tesseract", err)
+			if ctx.Err() != nil {
+				return nil, nil, err
+			}
+			failed = append(failed, PageError{Number: img.number, Err: err})
+			continue
+		}
+		pages = append(pages, Page{Number: img.number, Text: string(output), OCR: true})
+	}
+	return pages, failed, nil
+}
+
+// renderedImage 渲染得到的页面图片
+type renderedImage struct {
+	number int
+	path   string
+}
+
+// renderedPages 列出目录中渲染得到的图片，按页码排序
+func renderedPages(dir string) ([]renderedImage, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, newConvertError(CodeIO, "读取渲染结果失败: %w", err)
+	}
+
+	var images []renderedImage
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".png")
+		i := strings.LastIndex(name, "-")
+		if i < 0 || name == e.Name() {
+			continue
+		}
+		n, err := strconv.Atoi(name[i+1:])
+		if err != nil {
+			continue
+		}
+		images = append(images, renderedImage{number: n, path: filepath.Join(dir, e.Name())})
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].number < images[j].number })
+	return images, nil
+}
+
+// ocrBlankPages 对结果中没有文本层的页面执行OCR并替换其文本，识别失败的页面移到 result.Failed；
+// OCR整体失败（如超时、缺少命令）时保留原结果并返回错误
+func ocrBlankPages(ctx context.Context, data []byte, result *ExtractResult, opts ExtractOptions) error {
+	var spans []pageSpan
+	for _, p := range result.Pages {
+		if strings.TrimSpace(p.Text) != "" {
+			continue
+		}
+		if n := len(spans); n > 0 && spans[n-1].To == p.Number-1 {
+			spans[n-1].To = p.Number
+		} else {
+			spans = append(spans, pageSpan{From: p.Number, To: p.Number})
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	ocr, err := runOCR(ctx, data, spans, opts)
+	if err != nil {
+		log.Printf("OCR失败，保留原提取结果: %v", err)
+		return err
+	}
+
+	texts := make(map[int]string, len(ocr.Pages))
+	for _, p := range ocr.Pages {
+		texts[p.Number] = p.Text
+	}
+	for i := range result.Pages {
+		p := &result.Pages[i]
+		if text, ok := texts[p.Number]; ok {
+			p.Text = text
+			p.OCR = true
+			p.Backend = "ocr"
+			p.Lines, p.Words = nil, nil
+		}
+	}
+
+	// 识别失败的页面没有可用的文本，移到 Failed 中，与其他后端无法提取的页面一同报告
+	if len(ocr.Failed) > 0 {
+		failed := make(map[int]error, len(ocr.Failed))
+		for _, f := range ocr.Failed {
+			failed[f.Number] = f.Err
+		}
+		pages := result.Pages[:0]
+		for _, p := range result.Pages {
+			if err, ok := failed[p.Number]; ok {
+				log.Printf("第%d页OCR失败: %v", p.Number, err)
+				result.Failed = append(result.Failed, PageError{Number: p.Number, Err: err})
+				continue
+			}
+			pages = append(pages, p)
+		}
+		result.Pages = pages
+		sort.Slice(result.Failed, func(i, j int) bool { return result.Failed[i].Number < result.Failed[j].Number })
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// fakePdftoppm 模拟一份4页文档的 pdftoppm，每页输出一个写有页码的图片文件
+const fakePdftoppm = `#!/bin/sh
+first=1; last=4
+while [ $# -gt 2 ]; do
+	case "$1" in
+	-f) first=$2; shift ;;
+	-l) last=$2; shift ;;
+	esac
+	shift
+done
+[ "$last" -gt 4 ] && last=4
+i=$first
+while [ $i -le $last ]; do
+	echo "page $i" > "$2-$i.png"
+	i=$((i+1))
+done
+`
+
+// fakeTesseract 输出图片中的文字，第3页识别失败
+const fakeTesseract = `#!/bin/sh
+if grep -q "page 3" "$1"; then
+	echo "Error in pixReadStream" >&2
+	exit 1
+fi
+cat "$1"
+`
+
+// installFakeOCR 把模拟的 pdftoppm 和 tesseract 放在 PATH 的最前面
+func installFakeOCR(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("需要 sh")
+	}
+	dir := t.TempDir()
+	for name, script := range map[string]string{"pdftoppm": fakePdftoppm, "tesseract": fakeTesseract} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// pageNumbers 返回页面的页码
+func pageNumbers(pages []Page) []int {
+	var numbers []int
+	for _, p := range pages {
+		numbers = append(numbers, p.Number)
+	}
+	return numbers
+}
+
+// failedNumbers 返回失败页面的页码
+func failedNumbers(failed []PageError) []int {
+	var numbers []int
+	for _, f := range failed {
+		numbers = append(numbers, f.Number)
+	}
+	return numbers
+}
+
+func TestOCRRecordsFailedPages(t *testing.T) {
+	installFakeOCR(t)
+
+	tests := []struct {
+		pages      string
+		wantPages  []int
+		wantFailed []int
+	}{
+		{"", []int{1, 2, 4}, []int{3}},
+		{"1-2", []int{1, 2}, nil},
+		{"2-3", []int{2}, []int{3}},
+		{"3", nil, []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pages, func(t *testing.T) {
+			pageRange, err := parsePageRange(tt.pages)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := ocrExtractor{}.Extract(context.Background(), []byte("%PDF-1.4"), ExtractOptions{PageRange: pageRange})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pageNumbers(result.Pages); !slices.Equal(got, tt.wantPages) {
+				t.Errorf("页码 %v，期望 %v", got, tt.wantPages)
+			}
+			if got := failedNumbers(result.Failed); !slices.Equal(got, tt.wantFailed) {
+				t.Errorf("失败的页码 %v，期望 %v", got, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestOCRBlankPagesMovesFailedPages(t *testing.T) {
+	installFakeOCR(t)
+
+	result := &ExtractResult{
+		Pages: []Page{
+			{Number: 1},
+			{Number: 2, Text: "text layer"},
+			{Number: 3, Text: " "},
+			{Number: 4},
+		},
+		Failed: []PageError{{Number: 5, Err: newConvertError(CodeCorrupted, "bad page")}},
+	}
+	if err := ocrBlankPages(context.Background(), []byte("%PDF-1.4"), result, ExtractOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := pageNumbers(result.Pages); !slices.Equal(got, []int{1, 2, 4}) {
+		t.Fatalf("页码 %v", got)
+	}
+	if got := failedNumbers(result.Failed); !slices.Equal(got, []int{3, 5}) {
+		t.Fatalf("失败的页码 %v", got)
+	}
+	for _, p := range result.Pages {
+		if wantOCR := p.Number != 2; p.OCR != wantOCR {
+			t.Errorf("第%d页 OCR = %v", p.Number, p.OCR)
+		}
+	}
+	if result.Pages[0].Text != "page 1\n" {
+		t.Errorf("第1页文本 %q", result.Pages[0].Text)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// pdftotextLayout 调用 pdftotext 时是否使用 -layout 保留版面，由 pdftotext_layout 配置
+var pdftotextLayout = true
+
+func init() {
+	registerExtractor(pdftotextExtractor{})
+}
+
+// pdftotextExtractor 使用poppler的pdftotext命令行工具提取文本
+type pdftotextExtractor struct{}
+
+func (pdftotextExtractor) Name() string { return "pdftotext" }
+
+func (pdftotextExtractor) Capabilities() Capabilities {
+	return Capabilities{External: true}
+}
+
+func (pdftotextExtractor) Extract(ctx context.Context, data []byte, opts ExtractOptions) (*ExtractResult, error) {
+	// 检查pdftotext是否可用
+	if _, err := exec.LookPath("pdftotext"); err != nil {
+		return nil, newConvertError(CodeBackendMissing, "pdftotext命令不可用，请安装poppler-utils")
+	}
+
+	tmpPath, err := writeTempPDF(data)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpPath)
+
+	// 按区间执行pdftotext；加密文件依次尝试候选密码，成功后后续区间沿用同一密码
+	passwords := newPasswordTrier(opts)
+	result := &ExtractResult{}
+	for _, span := range opts.PageRange.spans() {
+		var output []byte
+		err := passwords.try(func(password string) error {
+			var err error
+			output, err = runPdftotext(ctx, tmpPath, password, span)
+			return err
+		})
+		if err != nil {
+			if spanPastEnd(err, len(result.Pages)) {
+				break
+			}
+			return nil, err
+		}
+
+		result.Pages = append(result.Pages, splitPdftotextPages(string(output), span.From)...)
+	}
+
+	return result, nil
+}
+
+// runPdftotext 对指定页码区间执行一次pdftotext
+func runPdftotext(ctx context.Context, path, password string, span pageSpan) ([]byte, error) {
+	span = span.capped()
+	var args []string
+	if pdftotextLayout {
+		args = append(args, "-layout")
+	}
+	args = append(args, "-f", strconv.Itoa(span.From))
+	if span.To > 0 {
+		args = append(args, "-l", strconv.Itoa(span.To))
+	}
+	if password != "" {
+		args = append(args, "-opw", password, "-upw", password)
+	}
+	args = append(args, path, "-")
+
+	output, err := externalCommand(ctx, "pdftotext", args...).Output()
+	if err != nil {
+		return nil, commandError(ctx, "pdftotext", err)
+	}
+	return output, nil
+}
+
+// splitPdftotextPages 按换页符拆分pdftotext的输出，first 为第一页的页码
+func splitPdftotextPages(output string, first int) []Page {
+	texts := strings.Split(output, "\f")
+	// pdftotext 在每页末尾输出换页符，最后一个元素为空
+	if len(texts) > 1 && strings.TrimSpace(texts[len(texts)-1]) == "" {
+		texts = texts[:len(texts)-1]
+	}
+
+	pages := make([]Page, len(texts))
+	for i, text := range texts {
+		pages[i] = Page{Number: first + i, Text: text}
+	}
+	return pages
+}
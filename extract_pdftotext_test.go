@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// fakePdftotext 模拟一份5页文档的 pdftotext：起始页超出页数时与poppler一样报错
+const fakePdftotext = `#!/bin/sh
+first=1; last=5
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-f) first=$2; shift ;;
+	-l) last=$2; shift ;;
+	esac
+	shift
+done
+[ "$last" -gt 5 ] && last=5
+if [ "$first" -gt "$last" ]; then
+	echo "Wrong page range given: the first page ($first) can not be after the last page ($last)." >&2
+	exit 99
+fi
+i=$first
+while [ $i -le $last ]; do
+	printf 'page %d\f' $i
+	i=$((i+1))
+done
+`
+
+func TestPdftotextPagesPastEnd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("需要 sh")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pdftotext"), []byte(fakePdftotext), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	tests := []struct {
+		pages   string
+		want    []int
+		wantErr ErrorCode
+	}{
+		{pages: "", want: []int{1, 2, 3, 4, 5}},
+		{pages: "1-3,10", want: []int{1, 2, 3}},
+		{pages: "2,4-8,20-", want: []int{2, 4, 5}},
+		{pages: "10", wantErr: CodeInvalidPageRange},
+		{pages: "6-", wantErr: CodeInvalidPageRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pages, func(t *testing.T) {
+			pageRange, err := parsePageRange(tt.pages)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := pdftotextExtractor{}.Extract(context.Background(), []byte("%PDF-1.4"), ExtractOptions{PageRange: pageRange})
+			if tt.wantErr != "" {
+				if errorCode(err) != tt.wantErr {
+					t.Fatalf("期望错误码 %s，得到 %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, p := range result.Pages {
+				got = append(got, p.Number)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("页码 %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/lu4p/unipdf/v3/extractor"
+	pdf "github.com/lu4p/unipdf/v3/model"
+)
+
+func init() {
+	registerExtractor(unipdfExtractor{})
+}
+
+// unipdfExtractor 使用unipdf库提取文本
+type unipdfExtractor struct{}
+
+func (unipdfExtractor) Name() string { return "unipdf" }
+
+func (unipdfExtractor) Capabilities() Capabilities {
+	return Capabilities{Positions: true}
+}
+
+func (unipdfExtractor) Extract(ctx context.Context, data []byte, opts ExtractOptions) (*ExtractResult, error) {
+	return runUnipdf(ctx, func() (*ExtractResult, error) {
+		return extractUnipdf(ctx, data, opts)
+	})
+}
+
+// unipdfSlots 整个进程同时运行的unipdf解析数上限，等于 workers。超时返回后仍在后台运行的解析
+// 继续占用名额直到结束，新的解析需要等待，被放弃的解析不会无限累积
+var unipdfSlots = sync.OnceValue(func() chan struct{} {
+	return make(chan struct{}, max(workers, 1))
+})
+
+// runUnipdf 在单独的goroutine中调用unipdf：解析单页时无法中断，上下文取消后立即返回，
+// 剩余的解析在后台结束后丢弃，结束前一直占用 unipdfSlots 的名额。
+// 畸形文件导致的panic转换为错误，不会使进程崩溃。
+func runUnipdf[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	type outcome struct {
+		value T
+		err   error
+	}
+	var zero T
+	slots := unipdfSlots()
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return zero, newConvertError(classifyError(ctx.Err()), "等待解析时被中断: %w", ctx.Err())
+	}
+
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() { <-slots }()
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: newConvertError(CodeCorrupted, "解析PDF时出错: %v", r)}
+			}
+		}()
+		value, err := fn()
+		done <- outcome{value, err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.value, o.err
+	case <-ctx.Done():
+		return zero, newConvertError(classifyError(ctx.Err()), "提取被中断: %w", ctx.Err())
+	}
+}
+
+// extractUnipdf 逐页提取文本，每页开始前检查上下文
+func extractUnipdf(ctx context.Context, data []byte, opts ExtractOptions) (*ExtractResult, error) {
+	// 创建bytes.Reader以支持Seek
+	reader := bytes.NewReader(data)
+
+	// 创建PDF阅读器
+	pdfReader, err := pdf.NewPdfReader(reader)
+	if err != nil {
+		return nil, newConvertError(CodeCorrupted, "创建PDF阅读器失败: %w", err)
+	}
+
+	// 检查加密
+	encrypted, err := pdfReader.IsEncrypted()
+	if err != nil {
+		return nil, newConvertError(CodeCorrupted, "读取加密信息失败: %w", err)
+	}
+	if encrypted {
+		if err := decryptReader(pdfReader, candidatePasswords(opts)); err != nil {
+			return nil, err
+		}
+	}
+
+	// 获取页数
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return nil, newConvertError(CodeCorrupted, "获取页数失败: %w", err)
+	}
+	if err := checkPageCount(numPages); err != nil {
+		return nil, err
+	}
+
+	// 提取所选页面的文本
+	pageNumbers := opts.PageRange.resolve(numPages)
+	if len(pageNumbers) == 0 {
+		return nil, newConvertError(CodeInvalidPageRange, "页码范围 %s 超出文档页数 %d", opts.PageRange, numPages)
+	}
+
+	result := &ExtractResult{
+		Pages:      make([]Page, 0, len(pageNumbers)),
+		TotalPages: numPages,
+	}
+	for _, i := range pageNumbers {
+		if err := ctx.Err(); err != nil {
+			return nil, newConvertError(classifyError(err), "第%d页: %w", i, err)
+		}
+
+		// 单页失败时记录下来继续处理其他页面，由后续后端补齐
+		page, err := pdfReader.GetPage(i)
+		if err != nil {
+			result.Failed = append(result.Failed, PageError{Number: i, Err: newConvertError(CodeCorrupted, "获取第%d页失败: %w", i, err)})
+			continue
+		}
+
+		ex, err := extractor.New(page)
+		if err != nil {
+			result.Failed = append(result.Failed, PageError{Number: i, Err: newConvertError(classifyError(err), "创建提取器失败（第%d页）: %w", i, err)})
+			continue
+		}
+
+		pageText, _, _, err := ex.ExtractPageText()
+		if err != nil {
+			result.Failed = append(result.Failed, PageError{Number: i, Err: newConvertError(classifyError(err), "提取文本失败（第%d页）: %w", i, err)})
+			continue
+		}
+
+		p := Page{Number: i, Text: pageText.Text()}
+		if box, err := page.GetMediaBox(); err == nil {
+			p.Width, p.Height = round2(box.Width()), round2(box.Height())
+		}
+		if opts.wantLines() || opts.wantWords() {
+			lines, words := textLayout(pageText.Marks().Elements())
+			if opts.wantLines() {
+				p.Lines = lines
+			}
+			if opts.wantWords() {
+				p.Words = words
+			}
+		}
+		result.Pages = append(result.Pages, p)
+	}
+
+	// 所有页面都失败时整份文档交给下一个后端
+	if len(result.Pages) == 0 && len(result.Failed) > 0 {
+		return nil, result.Failed[0].Err
+	}
+	return result, nil
+}
+
+// decryptReader 依次尝试空密码和候选密码解密
+func decryptReader(pdfReader *pdf.PdfReader, passwords []string) error {
+	for _, pw := range append([]string{""}, passwords...) {
+		ok, err := pdfReader.Decrypt([]byte(pw))
+		if err != nil {
+			return newConvertError(CodeEncrypted, "解密失败: %w", err)
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	if len(passwords) == 0 {
+		return newConvertError(CodeEncrypted, "文件已加密，需要密码")
+	}
+	return newConvertError(CodeEncrypted, "文件已加密，提供的%d个密码均不正确", len(passwords))
+}
+
+// textLayout 根据字符标记拼出行和单词的文本及坐标
+func textLayout(marks []extractor.TextMark) (lines, words []TextBox) {
+	var line, word boxBuilder
+	for _, m := range marks {
+		if m.Meta || strings.TrimSpace(m.Text) == "" {
+			// 空白和提取器插入的分隔符只用于切分，不参与坐标计算
+			if b, ok := word.box(); ok {
+				words = append(words, b)
+			}
+			word = boxBuilder{}
+			if strings.Contains(m.Text, "\n") {
+				if b, ok := line.box(); ok {
+					lines = append(lines, b)
+				}
+				line = boxBuilder{}
+			} else {
+				line.space()
+			}
+			continue
+		}
+		word.add(m)
+		line.add(m)
+	}
+
+	if b, ok := word.box(); ok {
+		words = append(words, b)
+	}
+	if b, ok := line.box(); ok {
+		lines = append(lines, b)
+	}
+	return lines, words
+}
+
+// boxBuilder 累积字符标记，计算文本和外接矩形
+type boxBuilder struct {
+	text  strings.Builder
+	bbox  pdf.PdfRectangle
+	font  string
+	size  float64
+	count int
+}
+
+func (b *boxBuilder) add(m extractor.TextMark) {
+	if b.count == 0 {
+		b.bbox = m.BBox
+		if m.Font != nil {
+			b.font = m.Font.BaseFont()
+		}
+		b.size = m.FontSize
+	} else {
+		b.bbox.Llx = math.Min(b.bbox.Llx, m.BBox.Llx)
+		b.bbox.Lly = math.Min(b.bbox.Lly, m.BBox.Lly)
+		b.bbox.Urx = math.Max(b.bbox.Urx, m.BBox.Urx)
+		b.bbox.Ury = math.Max(b.bbox.Ury, m.BBox.Ury)
+	}
+	b.text.WriteString(m.Text)
+	b.count++
+}
+
+// space 在行内插入单词间的空格
+func (b *boxBuilder) space() {
+	if b.count > 0 && !strings.HasSuffix(b.text.String(), " ") {
+		b.text.WriteString(" ")
+	}
+}
+
+func (b *boxBuilder) box() (TextBox, bool) {
+	if b.count == 0 {
+		return TextBox{}, false
+	}
+	return TextBox{
+		Text:     strings.TrimSpace(b.text.String()),
+		BBox:     [4]float64{round2(b.bbox.Llx), round2(b.bbox.Lly), round2(b.bbox.Urx), round2(b.bbox.Ury)},
+		Font:     b.font,
+		FontSize: round2(b.size),
+	}, true
+}
+
+// round2 保留两位小数，避免JSON中出现过长的浮点数
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
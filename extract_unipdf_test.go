@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunUnipdfBoundsAbandonedWork(t *testing.T) {
+	limit := cap(unipdfSlots())
+	release := make(chan struct{})
+	stuck := func() (int, error) {
+		<-release
+		return 0, nil
+	}
+
+	// 占满名额的解析超时返回，但仍在后台运行
+	for i := 0; i < limit; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := runUnipdf(ctx, stuck)
+		cancel()
+		if errorCode(err) != CodeTimeout {
+			t.Fatalf("错误码 = %s，want %s", errorCode(err), CodeTimeout)
+		}
+	}
+
+	// 名额释放前新的解析不会开始
+	started := make(chan struct{}, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	_, err := runUnipdf(ctx, func() (int, error) {
+		started <- struct{}{}
+		return 1, nil
+	})
+	cancel()
+	if errorCode(err) != CodeTimeout {
+		t.Fatalf("名额已满时错误码 = %s，want %s", errorCode(err), CodeTimeout)
+	}
+	select {
+	case <-started:
+		t.Fatal("名额已满时仍开始了新的解析")
+	default:
+	}
+
+	close(release)
+	got, err := runUnipdf(context.Background(), func() (int, error) { return 1, nil })
+	if err != nil || got != 1 {
+		t.Fatalf("名额释放后 runUnipdf = %d, %v", got, err)
+	}
+}
+
+func TestRunUnipdfRecoversPanic(t *testing.T) {
+	_, err := runUnipdf(context.Background(), func() (int, error) { panic("bad xref") })
+	if errorCode(err) != CodeCorrupted {
+		t.Fatalf("错误码 = %s，want %s", errorCode(err), CodeCorrupted)
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Capabilities 描述提取后端支持的能力
+type Capabilities struct {
+	Positions bool // 能否提供文本坐标和字体信息，不支持的后端输出的页面不含坐标
+	External  bool // 是否依赖外部命令
+}
+
+// ExtractOptions 控制一次提取的参数
+type ExtractOptions struct {
+	Backends  []string  // 按顺序尝试的后端，为空时使用 defaultBackends
+	Passwords []string  // 加密文件的候选密码，空密码总会自动尝试
+	PageRange PageRange // 要提取的页码范围，nil 表示全部页面
+	Format    string    // 输出格式，见 outputFormats
+	Positions string    // 文本坐标粒度：lines、words、all，为空时不提取坐标
+
+	OCR          bool   // 对没有文本层的页面执行OCR，所有后端都没有提取到文本时整份文档走OCR
+	OCRLanguages string // tesseract 的识别语言，如 chi_sim+eng，为空时使用 ocrLanguages
+
+	Meta bool // 读取文档元数据，输出 <name>.meta.json
+}
+
+// Page 单页的提取结果
+type Page struct {
+	Number  int       `json:"number"`            // 页码，从1开始
+	Text    string    `json:"text"`              // 页面文本
+	Width   float64   `json:"width,omitempty"`   // 页面宽度（PDF单位），后端无法获知时为0
+	Height  float64   `json:"height,omitempty"`  // 页面高度（PDF单位）
+	Lines   []TextBox `json:"lines,omitempty"`   // 按行的文本坐标，仅在请求时提供
+	Words   []TextBox `json:"words,omitempty"`   // 按单词的文本坐标，仅在请求时提供
+	OCR     bool      `json:"ocr,omitempty"`     // 文本是否由OCR识别得到
+	Backend string    `json:"backend,omitempty"` // 产生该页文本的后端
+}
+
+// PageError 单页提取失败的原因
+type PageError struct {
+	Number int
+	Err    error
+}
+
+// TextBox 一段文本及其在页面上的位置，坐标为PDF坐标系（原点在左下角）
+type TextBox struct {
+	Text     string     `json:"text"`
+	BBox     [4]float64 `json:"bbox"` // [llx, lly, urx, ury]
+	Font     string     `json:"font,omitempty"`
+	FontSize float64    `json:"fontSize,omitempty"`
+}
+
+// ExtractResult 提取结果
+type ExtractResult struct {
+	Backend    string        // 实际产生结果的后端
+	Pages      []Page        // 提取的页面，按页码顺序
+	TotalPages int           // 文档总页数，后端无法获知时为0
+	Failed     []PageError   // 后端无法提取的页面，不包含在 Pages 中
+	Meta       *DocumentMeta // 文档元数据，仅在 opts.Meta 时提供
+
+	incomplete bool // 因超时、后端缺失或OCR失败而缺少页面，环境变化后重新提取可能得到完整结果，不能缓存
+}
+
+// Text 返回整个文档的文本，每页以换行结尾
+func (r *ExtractResult) Text() string {
+	var b strings.Builder
+	for _, p := range r.Pages {
+		b.WriteString(p.Text)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// OCRPages 返回由OCR识别的页码
+func (r *ExtractResult) OCRPages() []int {
+	var pages []int
+	for _, p := range r.Pages {
+		if p.OCR {
+			pages = append(pages, p.Number)
+		}
+	}
+	return pages
+}
+
+// PageBackends 按后端列出各页的页码，所有页面来自同一后端时返回nil
+func (r *ExtractResult) PageBackends() map[string][]int {
+	backends := make(map[string][]int)
+	for _, p := range r.Pages {
+		backends[p.Backend] = append(backends[p.Backend], p.Number)
+	}
+	if len(backends) < 2 {
+		return nil
+	}
+	return backends
+}
+
+// FailedPages 返回所有后端都无法提取的页码
+func (r *ExtractResult) FailedPages() []int {
+	var pages []int
+	for _, f := range r.Failed {
+		pages = append(pages, f.Number)
+	}
+	return pages
+}
+
+// Extractor 是PDF文本提取后端
+type Extractor interface {
+	Name() string
+	Capabilities() Capabilities
+	Extract(ctx context.Context, data []byte, opts ExtractOptions) (*ExtractResult, error)
+}
+
+// extractors 已注册的提取后端，按名称索引
+var extractors = map[string]Extractor{}
+
+// defaultBackends 默认的后端回退顺序，可通过 -backends 参数修改
+var defaultBackends = []string{"unipdf", "pdftotext"}
+
+// fileTimeout 单个文件的提取期限，所有后端共用，0 表示不限制；由 file_timeout_seconds 配置
+var fileTimeout = 5 * time.Minute
+
+// registerExtractor 注册提取后端，名称重复时panic
+func registerExtractor(e Extractor) {
+	if _, ok := extractors[e.Name()]; ok {
+		panic(fmt.Sprintf("提取后端重复注册: %s", e.Name()))
+	}
+	extractors[e.Name()] = e
+}
+
+// extractorNames 返回所有已注册后端的名称
+func extractorNames() []string {
+	names := make([]string, 0, len(extractors))
+	for name := range extractors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseBackends 解析逗号分隔的后端列表并校验名称
+func parseBackends(s string) ([]string, error) {
+	var backends []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := extractors[name]; !ok {
+			return nil, fmt.Errorf("未知的提取后端: %s（可用: %s）", name, strings.Join(extractorNames(), ", "))
+		}
+		backends = append(backends, name)
+	}
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("至少需要指定一个提取后端")
+	}
+	return backends, nil
+}
+
+// extractText 按回退顺序依次尝试各后端，返回第一个成功的结果
+func extractText(ctx context.Context, data []byte, opts ExtractOptions) (*ExtractResult, error) {
+	backends := opts.Backends
+	if len(backends) == 0 {
+		backends = defaultBackends
+	}
+	// 启用OCR时，所有后端都没有提取到文本则最后走OCR
+	if opts.OCR && !slices.Contains(backends, "ocr") {
+		backends = append(slices.Clip(backends), "ocr")
+	}
+
+	if fileTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fileTimeout)
+		defer cancel()
+	}
+
+	var errs []error
+	for i, name := range backends {
+		ex, ok := extractors[name]
+		if !ok {
+			errs = append(errs, newConvertError(CodeUnknown, "未知的提取后端: %s", name))
+			continue
+		}
+
+		result, err := ex.Extract(ctx, data, opts)
+		if err == nil {
+			result.Backend = name
+			for j := range result.Pages {
+				result.Pages[j].Backend = name
+			}
+			err = checkPageCount(max(result.TotalPages, len(result.Pages)+len(result.Failed)))
+		}
+		if err == nil {
+			if len(result.Failed) > 0 {
+				fillFailedPages(ctx, data, result, backends[i+1:], opts)
+				for _, f := range result.Failed {
+					result.incomplete = result.incomplete || transientError(f.Err)
+				}
+			}
+			if strings.TrimSpace(result.Text()) == "" {
+				err = newConvertError(CodeEmptyText, "没有提取到文本")
+			}
+		}
+		if err == nil {
+			if opts.OCR && name != "ocr" && ocrBlankPages(ctx, data, result, opts) != nil {
+				result.incomplete = true
+			}
+			if opts.Meta {
+				result.Meta = documentMeta(ctx, data, result, opts)
+			}
+			if ctx.Err() != nil {
+				result.incomplete = true
+			}
+			return result, nil
+		}
+
+		err = wrapBackendError(name, err)
+		log.Printf("提取后端失败: %v", err)
+		errs = append(errs, err)
+
+		// 超时、取消或超出限制后不再尝试其他后端
+		if ctx.Err() != nil || errorCode(err) == CodeLimitExceeded {
+			break
+		}
+	}
+
+	return nil, &chainError{errs: errs}
+}
+
+// failedSpans 把失败的页码合并成连续区间
+func failedSpans(failed []PageError) PageRange {
+	spans := make([]pageSpan, len(failed))
+	for i, f := range failed {
+		spans[i] = pageSpan{From: f.Number, To: f.Number}
+	}
+	return mergeSpans(spans)
+}
+
+// transientError 判断错误是否可能随环境变化而消失，如调高期限或安装缺失的命令后重试
+func transientError(err error) bool {
+	switch errorCode(err) {
+	case CodeTimeout, CodeCanceled, CodeBackendMissing:
+		return true
+	}
+	return false
+}
+
+// fillFailedPages 用后续后端重新提取失败的页面，保留已成功页面的结果。
+// 失败的页面合并成连续区间，每个后端只调用一次（外部命令只写一次临时文件）；
+// 所有后端都失败的页面留在 result.Failed 中。
+func fillFailedPages(ctx context.Context, data []byte, result *ExtractResult, backends []string, opts ExtractOptions) {
+	for _, name := range backends {
+		ex, ok := extractors[name]
+		if !ok || len(result.Failed) == 0 || ctx.Err() != nil {
+			continue
+		}
+
+		pageOpts := opts
+		pageOpts.PageRange = failedSpans(result.Failed)
+		extracted, err := ex.Extract(ctx, data, pageOpts)
+		if err != nil {
+			err = wrapBackendError(name, err)
+			log.Printf("第%s页回退失败: %v", pageOpts.PageRange, err)
+		}
+
+		pages := make(map[int]*Page)
+		pageErrs := make(map[int]error)
+		if extracted != nil {
+			for j := range extracted.Pages {
+				pages[extracted.Pages[j].Number] = &extracted.Pages[j]
+			}
+			for _, f := range extracted.Failed {
+				pageErrs[f.Number] = wrapBackendError(name, f.Err)
+			}
+		}
+
+		var failed []PageError
+		for _, f := range result.Failed {
+			page, ok := pages[f.Number]
+			if !ok {
+				pageErr := err
+				if pageErr == nil {
+					pageErr = pageErrs[f.Number]
+				}
+				if pageErr == nil {
+					pageErr = wrapBackendError(name, newConvertError(CodeUnknown, "没有返回第%d页", f.Number))
+				}
+				failed = append(failed, PageError{Number: f.Number, Err: pageErr})
+				continue
+			}
+
+			log.Printf("第%d页改用 %s 提取（%v）", f.Number, name, f.Err)
+			page.Backend = name
+			result.Pages = append(result.Pages, *page)
+		}
+		result.Failed = failed
+	}
+
+	sort.Slice(result.Pages, func(i, j int) bool { return result.Pages[i].Number < result.Pages[j].Number })
+	for _, f := range result.Failed {
+		log.Printf("第%d页所有后端都失败，已跳过: %v", f.Number, f.Err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// stubExtractor 记录调用时的页码范围，对 failPages 中的页码报告失败
+type stubExtractor struct {
+	name      string
+	failPages []int
+	calls     *[]string
+}
+
+func (s stubExtractor) Name() string               { return s.name }
+func (s stubExtractor) Capabilities() Capabilities { return Capabilities{} }
+
+func (s stubExtractor) Extract(ctx context.Context, data []byte, opts ExtractOptions) (*ExtractResult, error) {
+	*s.calls = append(*s.calls, opts.PageRange.String())
+	result := &ExtractResult{TotalPages: 10}
+	for _, n := range opts.PageRange.resolve(10) {
+		if slices.Contains(s.failPages, n) {
+			result.Failed = append(result.Failed, PageError{Number: n, Err: newConvertError(CodeCorrupted, "第%d页损坏", n)})
+			continue
+		}
+		result.Pages = append(result.Pages, Page{Number: n, Text: s.name})
+	}
+	return result, nil
+}
+
+func TestFillFailedPagesBySpan(t *testing.T) {
+	var calls []string
+	extractors["stub-a"] = stubExtractor{name: "stub-a", calls: &calls}
+	extractors["stub-b"] = stubExtractor{name: "stub-b", failPages: []int{4, 9}, calls: &calls}
+	defer delete(extractors, "stub-a")
+	defer delete(extractors, "stub-b")
+
+	result := &ExtractResult{Pages: []Page{{Number: 1}, {Number: 6}, {Number: 10}}}
+	for _, n := range []int{2, 3, 4, 5, 7, 8, 9} {
+		result.Failed = append(result.Failed, PageError{Number: n, Err: newConvertError(CodeCorrupted, "损坏")})
+	}
+
+	fillFailedPages(context.Background(), nil, result, []string{"stub-b", "stub-a"}, ExtractOptions{})
+
+	// 每个后端只调用一次，连续的失败页合并成区间
+	if want := []string{"2-5,7-9", "4,9"}; !slices.Equal(calls, want) {
+		t.Fatalf("调用 %q，期望 %q", calls, want)
+	}
+	if len(result.Failed) != 0 {
+		t.Fatalf("仍有失败页面: %v", result.Failed)
+	}
+	var numbers []int
+	for _, p := range result.Pages {
+		numbers = append(numbers, p.Number)
+		want := "stub-b"
+		if p.Number == 4 || p.Number == 9 {
+			want = "stub-a"
+		}
+		if p.Number != 1 && p.Number != 6 && p.Number != 10 && p.Backend != want {
+			t.Errorf("第%d页来自 %s，期望 %s", p.Number, p.Backend, want)
+		}
+	}
+	if !slices.Equal(numbers, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}) {
+		t.Fatalf("页码 %v", numbers)
+	}
+}
@@ -1,8 +1,13 @@
 module github.com/gpencil/pdf2textV2
 
-go 1.24.0
+go 1.25.0
 
-require github.com/lu4p/unipdf/v3 v3.7.1
+require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/fsnotify/fsnotify v1.10.1
+	github.com/lu4p/unipdf/v3 v3.7.1
+	gopkg.in/yaml.v2 v2.4.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -12,7 +17,6 @@ require (
 	github.com/stretchr/testify v1.4.0 // indirect
 	github.com/unidoc/unitype v0.2.0 // indirect
 	golang.org/x/image v0.0.0-20181116024801-cd38e8056d9b // indirect
-	golang.org/x/sys v0.0.0-20200523222454-059865788121 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 	golang.org/x/text v0.3.2 // indirect
-	gopkg.in/yaml.v2 v2.2.2 // indirect
 )
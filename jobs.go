@@ -0,0 +1,698 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// jobTTL 已完成任务的保留时间，超时后删除任务及其临时文件
+const jobTTL = time.Hour
+
+// 任务和文件的状态
+const (
+	statusPending = "pending"
+	statusRunning = "running"
+	statusSuccess = "success"
+	statusFailed  = "failed"
+	statusSkipped = "skipped" // 按策略保留了已有的输出
+	statusDone    = "done"
+)
+
+// 任务的输出方式
+const (
+	modeZip   = "zip"
+	modeLocal = "local"
+)
+
+// jobFile 任务中单个文件的状态
+type jobFile struct {
+	Name   string `json:"name"`
+	Path   string `json:"path,omitempty"`
+	Status string `json:"status"`
+	FileResult
+
+	spool   string    // 上传文件在临时目录中的副本，转换服务器上的目录时为源文件本身
+	zipName string    // ZIP模式下的条目名
+	modTime time.Time // 源文件的修改时间，未知时为零值
+	size    int64     // 源文件的大小，用于检查点
+}
+
+// jobSnapshot 任务状态的只读副本，用于JSON输出
+type jobSnapshot struct {
+	ID          string    `json:"id"`
+	Mode        string    `json:"mode"`
+	Status      string    `json:"status"`
+	OutputPath  string    `json:"outputPath,omitempty"`
+	DownloadURL string    `json:"downloadUrl,omitempty"`
+	Total       int       `json:"total"`
+	Completed   int       `json:"completed"`
+	Succeeded   int       `json:"successCount"`
+	Skipped     int       `json:"skippedCount"`
+	Failed      int       `json:"failedCount"`
+	Files       []jobFile `json:"files"`
+	Created     time.Time `json:"created"`
+}
+
+// jobEvent 推送给SSE订阅者的事件
+type jobEvent struct {
+	Type string      // "file" 或 "done"
+	Data interface{} // 事件数据，编码为JSON
+}
+
+// fileEvent 单个文件状态变化的事件数据
+type fileEvent struct {
+	Index     int     `json:"index"`
+	File      jobFile `json:"file"`
+	Completed int     `json:"completed"`
+	Total     int     `json:"total"`
+}
+
+// job 一次异步批量转换任务
+type job struct {
+	mu          sync.Mutex
+	id          string
+	mode        string
+	status      string
+	outputPath  string
+	target      outputTarget
+	files       []jobFile
+	completed   int
+	succeeded   int
+	skipped     int
+	failed      int
+	created     time.Time
+	finished    time.Time
+	tmpDir      string
+	inputDir    string   // 转换服务器上的目录时的输入目录
+	existing    string   // 本地保存时已有输出的处理策略
+	journal     *journal // 检查点，只有转换服务器上的目录时记录
+	zip         *jobZip  // ZIP模式的输出
+	retryFailed bool     // 从检查点恢复时重新转换失败的文件
+	opts        ExtractOptions
+	subscribers map[chan jobEvent]struct{}
+}
+
+// jobStore 内存中的任务表
+type jobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+var jobs = &jobStore{jobs: make(map[string]*job)}
+
+func (s *jobStore) add(j *job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[j.id] = j
+}
+
+func (s *jobStore) get(id string) (*job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	return j, ok
+}
+
+// expire 删除已完成且超过保留时间的任务
+func (s *jobStore) expire(now time.Time) {
+	s.mu.Lock()
+	var expired []*job
+	for id, j := range s.jobs {
+		j.mu.Lock()
+		if !j.finished.IsZero() && now.Sub(j.finished) > jobTTL {
+			expired = append(expired, j)
+			delete(s.jobs, id)
+		}
+		j.mu.Unlock()
+	}
+	s.mu.Unlock()
+
+	for _, j := range expired {
+		os.RemoveAll(j.tmpDir)
+	}
+}
+
+// startJobJanitor 定期清理过期任务
+func startJobJanitor() {
+	go func() {
+		for now := range time.Tick(time.Minute) {
+			jobs.expire(now)
+		}
+	}()
+}
+
+// newJobID 生成随机任务ID
+func newJobID() string {
+	return randomToken()
+}
+
+// snapshot 返回任务当前状态的副本，调用方需持有 j.mu
+func (j *job) snapshot() jobSnapshot {
+	snap := jobSnapshot{
+		ID:         j.id,
+		Mode:       j.mode,
+		Status:     j.status,
+		OutputPath: j.outputPath,
+		Total:      len(j.files),
+		Completed:  j.completed,
+		Succeeded:  j.succeeded,
+		Skipped:    j.skipped,
+		Failed:     j.failed,
+		Files:      append([]jobFile(nil), j.files...),
+		Created:    j.created,
+	}
+	if j.mode == modeZip && j.status == statusDone {
+		snap.DownloadURL = "/api/jobs/" + j.id + "/download"
+	}
+	return snap
+}
+
+// subscribe 注册SSE订阅者，返回事件通道和订阅时的状态
+func (j *job) subscribe() (chan jobEvent, jobSnapshot) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// 每个文件最多产生两次事件，再加上完成事件，缓冲足够时发送不会阻塞
+	ch := make(chan jobEvent, 2*len(j.files)+1)
+	j.subscribers[ch] = struct{}{}
+	return ch, j.snapshot()
+}
+
+func (j *job) unsubscribe(ch chan jobEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.subscribers, ch)
+}
+
+// publish 向所有订阅者发送事件，调用方需持有 j.mu
+func (j *job) publish(ev jobEvent) {
+	for ch := range j.subscribers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// setFile 更新单个文件的状态并通知订阅者，result 为空表示尚无结果
+func (j *job) setFile(i int, status string, result *FileResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f := &j.files[i]
+	f.Status = status
+	if result != nil {
+		f.FileResult = *result
+	}
+	switch status {
+	case statusSuccess:
+		j.completed++
+		j.succeeded++
+	case statusSkipped:
+		j.completed++
+		j.skipped++
+	case statusFailed:
+		j.completed++
+		j.failed++
+	}
+
+	j.publish(jobEvent{Type: "file", Data: fileEvent{
+		Index:     i,
+		File:      *f,
+		Completed: j.completed,
+		Total:     len(j.files),
+	}})
+}
+
+// finish 标记任务结束并通知订阅者
+func (j *job) finish(status string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.status = status
+	j.finished = time.Now()
+	j.publish(jobEvent{Type: "done", Data: j.snapshot()})
+}
+
+// run 在后台执行任务
+func (j *job) run(ctx context.Context) {
+	j.mu.Lock()
+	j.status = statusRunning
+	j.mu.Unlock()
+
+	if j.zip != nil {
+		// ZIP条目按上传顺序写入
+		forEachOrdered(workers, len(j.files), func(i int) func() {
+			return j.zip.write(j.convertFile(ctx, i))
+		})
+	} else {
+		forEachParallel(workers, len(j.files), func(i int) {
+			j.convertFile(ctx, i)
+		})
+	}
+	if err := j.journal.Close(); err != nil {
+		log.Printf("关闭检查点失败: %v\n", err)
+	}
+
+	// 上传文件的副本已不再需要；转换服务器上的目录时 spool 是源文件，不能删除
+	if j.inputDir == "" {
+		for _, f := range j.files {
+			os.Remove(f.spool)
+		}
+	}
+
+	j.mu.Lock()
+	succeeded, skipped, failed := j.succeeded, j.skipped, j.failed
+	j.mu.Unlock()
+
+	switch j.mode {
+	case modeZip:
+		results := make([]FileResult, len(j.files))
+		for i, f := range j.files {
+			results[i] = f.FileResult
+		}
+		err := j.zip.close(results)
+		if succeeded == 0 {
+			os.Remove(j.zipPath())
+		} else if err != nil {
+			log.Printf("写入ZIP失败: %v\n", err)
+			j.finish(statusFailed)
+			return
+		}
+	case modeLocal:
+		if err := openFolder(j.outputPath); err != nil {
+			log.Printf("打开文件夹失败: %v\n", err)
+		}
+	}
+
+	log.Printf("任务 %s 完成: 成功 %d, 跳过 %d, 失败 %d\n", j.id, succeeded, skipped, failed)
+	if succeeded == 0 && skipped == 0 {
+		j.finish(statusFailed)
+		return
+	}
+	j.finish(statusDone)
+}
+
+// convertFile 转换任务中的第 i 个文件并记录结果。ZIP模式下转换成功时返回写入条目的操作，
+// 由调用方按上传顺序写入
+func (j *job) convertFile(ctx context.Context, i int) (entry func(*zip.Writer) error) {
+	j.setFile(i, statusRunning, nil)
+
+	f := j.files[i]
+	input := f.Input
+	if e, ok := j.journal.resumed(f.Path, f.size, f.modTime, j.retryFailed); ok {
+		j.setFile(i, e.Status, &e.FileResult)
+		return nil
+	}
+	if output, ok := j.existingOutput(f); ok {
+		log.Printf("跳过（已有输出）: %s\n", input)
+		j.setFile(i, statusSkipped, &FileResult{Input: input, Output: output, Skipped: true})
+		j.record(f, statusSkipped, FileResult{Input: input, Output: output, Skipped: true})
+		return nil
+	}
+	text, result := convertSpooledFile(ctx, f.spool, input, j.opts)
+	if result.OK() {
+		if j.mode == modeLocal {
+			output, err := writeLocalOutput(j.target, f.Name, f.Path, text, j.opts.Format, result.Meta)
+			if err != nil {
+				result.setError(err)
+			} else {
+				result.Output = output
+			}
+		} else {
+			meta := result.Meta
+			entry = func(zipWriter *zip.Writer) error {
+				return writeZipOutput(zipWriter, f.zipName, text, meta)
+			}
+			result.Output = f.zipName
+		}
+	}
+
+	if !result.OK() {
+		log.Printf("转换失败 %s: %s\n", input, result.Error)
+		j.setFile(i, statusFailed, &result)
+		// 服务停止时未完成的文件不记录，恢复时重新转换
+		if ctx.Err() == nil {
+			j.record(f, statusFailed, result)
+		}
+		return nil
+	}
+
+	log.Printf("转换成功: %s\n", input)
+	j.setFile(i, statusSuccess, &result)
+	j.record(f, statusSuccess, result)
+	return entry
+}
+
+// record 把文件的处理结果写入检查点
+func (j *job) record(f jobFile, status string, result FileResult) {
+	entry := journalEntry{Path: f.Path, Size: f.size, ModTime: f.modTime, Status: status, FileResult: result}
+	if err := j.journal.record(entry); err != nil {
+		log.Printf("任务 %s %v\n", j.id, err)
+	}
+}
+
+// existingOutput 本地保存时按策略检查已有的输出，应跳过时返回其路径
+func (j *job) existingOutput(f jobFile) (string, bool) {
+	if j.mode != modeLocal || j.existing == existingOverwrite {
+		return "", false
+	}
+	rel, err := localOutputRel(f.Name, f.Path, j.opts.Format)
+	if err != nil {
+		return "", false
+	}
+	info, output := j.target.stat(rel)
+	return output, skipExisting(j.existing, info, f.modTime)
+}
+
+// zipPath 任务生成的ZIP文件路径
+func (j *job) zipPath() string {
+	return filepath.Join(j.tmpDir, "converted-texts.zip")
+}
+
+// jobZip ZIP模式任务的输出文件。转换结果按上传顺序逐个写入，
+// 内存中最多只保留 workers 个文件的文本
+type jobZip struct {
+	file   *os.File
+	writer *zip.Writer
+	err    error // 第一个写入错误，之后的条目不再写入
+}
+
+// newJobZip 创建任务的ZIP文件
+func newJobZip(path string) (*jobZip, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &jobZip{file: file, writer: zip.NewWriter(file)}, nil
+}
+
+// write 返回写入条目的操作，entry 为 nil 表示该文件没有输出
+func (z *jobZip) write(entry func(*zip.Writer) error) func() {
+	if entry == nil {
+		return nil
+	}
+	return func() {
+		if z.err == nil {
+			z.err = entry(z.writer)
+		}
+	}
+}
+
+// close 写入清单并关闭ZIP文件，返回过程中的第一个错误
+func (z *jobZip) close(results []FileResult) error {
+	err := z.err
+	if err == nil {
+		err = writeZipReport(z.writer, results)
+	}
+	if closeErr := z.writer.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := z.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// convertSpooledFile 转换临时目录中的上传文件副本
+func convertSpooledFile(ctx context.Context, path, input string, opts ExtractOptions) (string, FileResult) {
+	return convertReader(ctx, input, func() (io.ReadCloser, error) {
+		return os.Open(path)
+	}, opts)
+}
+
+// createJobHandler 创建异步转换任务并立即返回任务ID
+func createJobHandler(w http.ResponseWriter, r *http.Request) {
+	// 输出方式在表单中，读取上传前先拒绝没有任何转换权限的请求
+	if !hasPermission(r, permZip) && !hasPermission(r, permLocal) {
+		writeJSONError(w, permissionError(permZip), http.StatusForbidden)
+		return
+	}
+
+	// 上传的PDF逐个写入任务临时目录，不在内存中缓存，也不再复制
+	tmpDir, err := os.MkdirTemp("", "pdf2txt-job-*")
+	if err != nil {
+		writeJSONError(w, fmt.Sprintf("创建临时目录失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	started := false
+	defer func() {
+		if !started {
+			os.RemoveAll(tmpDir)
+		}
+	}()
+
+	form, uploads, err := readMultipartStream(r, tmpDir)
+	if err != nil {
+		writeJSONError(w, fmt.Sprintf("解析表单失败: %v", err), limitErrorStatus(err, http.StatusBadRequest))
+		return
+	}
+	if len(uploads) == 0 {
+		writeJSONError(w, "没有上传PDF文件", http.StatusBadRequest)
+		return
+	}
+	paths := form.Value["paths"]
+
+	opts, err := extractOptionsFromForm(form)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mode := formValue(form, "mode")
+	if mode == "" {
+		mode = modeZip
+	}
+	if mode != modeZip && mode != modeLocal {
+		writeJSONError(w, fmt.Sprintf("未知的输出方式: %s", mode), http.StatusBadRequest)
+		return
+	}
+
+	if !hasPermission(r, mode) {
+		writeJSONError(w, permissionError(mode), http.StatusForbidden)
+		return
+	}
+
+	collision, err := parseCollisionPolicy(formValue(form, "collision"))
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	existing, err := parseExistingPolicy(formValue(form, "existing"))
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	modTimes := parseModTimes(form.Value["mtimes"])
+
+	j := &job{
+		id:          newJobID(),
+		mode:        mode,
+		status:      statusPending,
+		created:     time.Now(),
+		tmpDir:      tmpDir,
+		existing:    existing,
+		opts:        opts,
+		subscribers: make(map[chan jobEvent]struct{}),
+	}
+
+	// 本地保存时相对路径和输出目录都必须位于允许的根目录内
+	if mode == modeLocal {
+		if paths, err = sanitizePaths(paths); err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if j.target, err = localOutputTarget(form); err != nil {
+			writeJSONError(w, err.Error(), pathErrorStatus(err))
+			return
+		}
+		j.outputPath = j.target.path()
+	}
+
+	for _, upload := range uploads {
+		f := jobFile{
+			Name:   upload.Filename,
+			Status: statusPending,
+			spool:  upload.Path,
+		}
+		if upload.Index < len(paths) {
+			f.Path = paths[upload.Index]
+		}
+		if upload.Index < len(modTimes) {
+			f.modTime = modTimes[upload.Index]
+		}
+		f.Input = f.Name
+		if f.Path != "" {
+			f.Input = f.Path
+		}
+		j.files = append(j.files, f)
+	}
+
+	if mode == modeLocal {
+		filenames := make([]string, len(j.files))
+		relPaths := make([]string, len(j.files))
+		for i, f := range j.files {
+			filenames[i], relPaths[i] = f.Name, f.Path
+		}
+		if err := j.target.checkOutputs(filenames, relPaths, opts.Format); err != nil {
+			writeJSONError(w, err.Error(), pathErrorStatus(err))
+			return
+		}
+	}
+
+	if mode == modeZip {
+		inputs := make([]string, len(j.files))
+		for i, f := range j.files {
+			inputs[i] = f.Input
+		}
+		names, err := zipEntryNames(inputs, opts.Format, collision, opts.Meta)
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		for i := range j.files {
+			j.files[i].zipName = names[i]
+		}
+		if j.zip, err = newJobZip(j.zipPath()); err != nil {
+			writeJSONError(w, fmt.Sprintf("创建ZIP文件失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	started = true
+	startJob(w, j)
+}
+
+// startJob 登记任务并在后台执行，返回202和任务的初始状态
+func startJob(w http.ResponseWriter, j *job) {
+	jobs.add(j)
+	go j.run(context.Background())
+	log.Printf("任务 %s 已创建: %d 个文件\n", j.id, len(j.files))
+
+	j.mu.Lock()
+	snap := j.snapshot()
+	j.mu.Unlock()
+
+	w.Header().Set("Location", "/api/jobs/"+j.id)
+	writeJSON(w, http.StatusAccepted, snap)
+}
+
+// getJobHandler 返回任务及每个文件的状态
+func getJobHandler(w http.ResponseWriter, r *http.Request) {
+	j, ok := jobs.get(r.PathValue("id"))
+	if !ok {
+		writeJSONError(w, "任务不存在", http.StatusNotFound)
+		return
+	}
+
+	j.mu.Lock()
+	snap := j.snapshot()
+	j.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, snap)
+}
+
+// jobEventsHandler 以Server-Sent Events推送任务进度
+func jobEventsHandler(w http.ResponseWriter, r *http.Request) {
+	j, ok := jobs.get(r.PathValue("id"))
+	if !ok {
+		writeJSONError(w, "任务不存在", http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, "不支持流式响应", http.StatusInternalServerError)
+		return
+	}
+
+	ch, snap := j.subscribe()
+	defer j.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	// 先发送当前状态，客户端据此渲染文件列表
+	writeSSE(w, "snapshot", snap)
+	if snap.Status == statusDone || snap.Status == statusFailed {
+		writeSSE(w, "done", snap)
+		flusher.Flush()
+		return
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case ev := <-ch:
+			writeSSE(w, ev.Type, ev.Data)
+			flusher.Flush()
+			if ev.Type == "done" {
+				return
+			}
+		}
+	}
+}
+
+// downloadJobHandler 下载ZIP模式任务的结果
+func downloadJobHandler(w http.ResponseWriter, r *http.Request) {
+	j, ok := jobs.get(r.PathValue("id"))
+	if !ok {
+		writeJSONError(w, "任务不存在", http.StatusNotFound)
+		return
+	}
+
+	j.mu.Lock()
+	mode, status := j.mode, j.status
+	j.mu.Unlock()
+
+	if mode != modeZip {
+		writeJSONError(w, "该任务没有可下载的ZIP文件", http.StatusBadRequest)
+		return
+	}
+	if status != statusDone {
+		writeJSONError(w, "任务尚未完成或全部失败", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=converted-texts.zip")
+	http.ServeFile(w, r, j.zipPath())
+}
+
+// writeSSE 写入一条SSE事件
+func writeSSE(w io.Writer, event string, data interface{}) {
+	payload, _ := json.Marshal(data)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+}
+
+// writeJSON 以JSON格式写入响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError 以JSON格式返回错误，与前端读取 result.error 的约定一致
+func writeJSONError(w http.ResponseWriter, msg string, status int) {
+	writeJSON(w, status, map[string]interface{}{
+		"success": false,
+		"error":   msg,
+	})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// journalName 检查点文件的默认名称，位于输出目录下
+const journalName = ".pdf2txt-journal.jsonl"
+
+// errJournalMismatch 恢复时检查点记录的参数与本次不同
+var errJournalMismatch = errors.New("检查点的输出位置或格式参数与本次不同")
+
+// journalVersion 检查点格式的版本
+const journalVersion = 1
+
+// journalHeader 检查点的第一行，记录影响输出的参数。恢复时参数必须一致，
+// 否则已完成的文件与本次要求的输出不符
+type journalHeader struct {
+	Version   int       `json:"version"`
+	Output    string    `json:"output"`
+	Format    string    `json:"format"`
+	Pages     string    `json:"pages,omitempty"`
+	Positions string    `json:"positions,omitempty"`
+	Meta      bool      `json:"meta,omitempty"`
+	Created   time.Time `json:"created"`
+}
+
+// journalEntry 单个文件的处理结果，源文件的大小和修改时间用于判断记录是否过期
+type journalEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Status  string    `json:"status"`
+	FileResult
+}
+
+// journal 批量转换的检查点，每处理完一个文件追加一行，进程中途退出后可以从中恢复。
+// nil 表示不记录检查点
+type journal struct {
+	mu      sync.Mutex
+	file    *os.File
+	entries map[string]journalEntry // 恢复时读取到的记录，同一路径以最后一条为准
+}
+
+// newJournalHeader 根据输出位置和提取选项生成检查点的文件头
+func newJournalHeader(output string, opts ExtractOptions) journalHeader {
+	return journalHeader{
+		Version:   journalVersion,
+		Output:    output,
+		Format:    opts.Format,
+		Pages:     opts.PageRange.String(),
+		Positions: opts.Positions,
+		Meta:      opts.Meta,
+		Created:   time.Now(),
+	}
+}
+
+// matches 判断两次运行的参数是否一致，创建时间不参与比较
+func (h journalHeader) matches(other journalHeader) bool {
+	h.Created = other.Created
+	return h == other
+}
+
+// openJournal 打开检查点。resume 时读取已有的记录并继续追加，文件不存在时从头开始；
+// 否则清空重新记录。open 按给定的标志打开检查点文件
+func openJournal(open func(flag int) (*os.File, error), header journalHeader, resume bool) (*journal, error) {
+	flag := os.O_RDWR | os.O_CREATE
+	if !resume {
+		flag |= os.O_TRUNC
+	}
+	file, err := open(flag)
+	if err != nil {
+		return nil, fmt.Errorf("打开检查点失败: %w", err)
+	}
+
+	j := &journal{file: file, entries: make(map[string]journalEntry)}
+	if resume {
+		if err := j.load(header); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if len(j.entries) == 0 {
+		// 没有可恢复的记录时重写文件头
+		if err := file.Truncate(0); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("写入检查点失败: %w", err)
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("写入检查点失败: %w", err)
+		}
+		if err := j.writeLine(header); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return j, nil
+}
+
+// load 读取已有的记录。最后一行不完整（写入时进程退出）时截掉，后续记录从该位置继续追加
+func (j *journal) load(header journalHeader) error {
+	r := bufio.NewReader(j.file)
+	var offset int64
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("读取检查点失败: %w", err)
+		}
+
+		if n == 1 {
+			var h journalHeader
+			if err := json.Unmarshal(line, &h); err != nil || h.Version != journalVersion {
+				return fmt.Errorf("检查点第 1 行不是有效的文件头")
+			}
+			if !h.matches(header) {
+				return fmt.Errorf("%w（输出 %s, 格式 %s），请去掉恢复选项重新开始", errJournalMismatch, h.Output, h.Format)
+			}
+		} else {
+			var e journalEntry
+			if err := json.Unmarshal(line, &e); err != nil {
+				return fmt.Errorf("检查点第 %d 行格式错误: %w", n, err)
+			}
+			j.entries[e.Path] = e
+		}
+		offset += int64(len(line))
+	}
+
+	if err := j.file.Truncate(offset); err != nil {
+		return fmt.Errorf("读取检查点失败: %w", err)
+	}
+	if _, err := j.file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("读取检查点失败: %w", err)
+	}
+	return nil
+}
+
+// resumed 返回检查点中已处理过的文件的记录。成功和跳过的文件不再转换，失败的文件在 retryFailed 时
+// 重新转换；源文件在记录之后被修改过的一律重新转换
+func (j *journal) resumed(path string, size int64, modTime time.Time, retryFailed bool) (journalEntry, bool) {
+	if j == nil {
+		return journalEntry{}, false
+	}
+	e, ok := j.entries[path]
+	if !ok || e.Size != size || !e.ModTime.Equal(modTime) {
+		return journalEntry{}, false
+	}
+	if e.Status == statusFailed && retryFailed {
+		return journalEntry{}, false
+	}
+	return e, true
+}
+
+// record 追加单个文件的处理结果
+func (j *journal) record(e journalEntry) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.writeLine(e)
+}
+
+// writeLine 写入一行JSON，调用方需持有 j.mu 或独占 j
+func (j *journal) writeLine(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("写入检查点失败: %w", err)
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入检查点失败: %w", err)
+	}
+	return nil
+}
+
+// Close 关闭检查点文件
+func (j *journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.file.Close()
+}
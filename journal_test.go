@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// journalLines 把文件头和记录编码为检查点的各行
+func journalLines(t *testing.T, header journalHeader, entries ...journalEntry) string {
+	t.Helper()
+	var b strings.Builder
+	for _, v := range append([]any{header}, toAny(entries)...) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Write(append(data, '\n'))
+	}
+	return b.String()
+}
+
+func toAny(entries []journalEntry) []any {
+	values := make([]any, len(entries))
+	for i, e := range entries {
+		values[i] = e
+	}
+	return values
+}
+
+func TestOpenJournalResume(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	header := newJournalHeader("/out", ExtractOptions{Format: formatText})
+	done := journalEntry{Path: "a.pdf", Size: 10, ModTime: modTime, Status: statusSuccess}
+	failed := journalEntry{Path: "b.pdf", Size: 20, ModTime: modTime, Status: statusFailed}
+	complete := journalLines(t, header, done, failed)
+
+	other := header
+	other.Format = formatJSON
+
+	tests := []struct {
+		name        string
+		content     string // 为空表示文件不存在
+		resume      bool
+		wantErr     string
+		wantEntries []string
+		wantContent string // 打开后文件的内容，为空表示只有新的文件头
+	}{
+		{
+			name:        "完整的检查点",
+			content:     complete,
+			resume:      true,
+			wantEntries: []string{"a.pdf", "b.pdf"},
+			wantContent: complete,
+		},
+		{
+			name:        "最后一行不完整",
+			content:     complete + `{"path":"c.pdf","si`,
+			resume:      true,
+			wantEntries: []string{"a.pdf", "b.pdf"},
+			wantContent: complete,
+		},
+		{
+			name:        "最后一行缺少换行",
+			content:     complete + `{"path":"c.pdf"}`,
+			resume:      true,
+			wantEntries: []string{"a.pdf", "b.pdf"},
+			wantContent: complete,
+		},
+		{
+			name:    "文件头不完整",
+			content: `{"version":1,"out`,
+			resume:  true,
+		},
+		{
+			name:   "文件不存在",
+			resume: true,
+		},
+		{
+			name:    "不恢复时清空",
+			content: complete,
+		},
+		{
+			name:    "参数不同",
+			content: journalLines(t, other, done),
+			resume:  true,
+			wantErr: errJournalMismatch.Error(),
+		},
+		{
+			name:    "中间的行损坏",
+			content: journalLines(t, header) + "not json\n" + `{"path":"a.pdf"}` + "\n",
+			resume:  true,
+			wantErr: "检查点第 2 行格式错误",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), journalName)
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			open := func(flag int) (*os.File, error) { return os.OpenFile(path, flag, 0644) }
+
+			j, err := openJournal(open, header, tt.resume)
+			if tt.wantErr != "" {
+				if err == nil {
+					j.Close()
+					t.Fatal("应返回错误")
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("错误 = %v，want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer j.Close()
+
+			if len(j.entries) != len(tt.wantEntries) {
+				t.Fatalf("记录数 = %d，want %d", len(j.entries), len(tt.wantEntries))
+			}
+			for _, p := range tt.wantEntries {
+				if _, ok := j.entries[p]; !ok {
+					t.Errorf("缺少 %s 的记录", p)
+				}
+			}
+
+			want := tt.wantContent
+			if want == "" {
+				want = journalLines(t, header)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != want {
+				t.Fatalf("检查点内容 = %q，want %q", data, want)
+			}
+
+			// 新的记录接在最后一个完整的行之后
+			next := journalEntry{Path: "d.pdf", Size: 1, ModTime: modTime, Status: statusSkipped}
+			if err := j.record(next); err != nil {
+				t.Fatal(err)
+			}
+			data, err = os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			line, _ := json.Marshal(next)
+			if string(data) != want+string(line)+"\n" {
+				t.Fatalf("追加后的内容 = %q", data)
+			}
+		})
+	}
+}
+
+func TestJournalResumed(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	j := &journal{entries: map[string]journalEntry{
+		"ok.pdf":      {Path: "ok.pdf", Size: 10, ModTime: modTime, Status: statusSuccess},
+		"skipped.pdf": {Path: "skipped.pdf", Size: 10, ModTime: modTime, Status: statusSkipped},
+		"failed.pdf":  {Path: "failed.pdf", Size: 10, ModTime: modTime, Status: statusFailed},
+	}}
+
+	tests := []struct {
+		name        string
+		path        string
+		size        int64
+		modTime     time.Time
+		retryFailed bool
+		want        bool
+	}{
+		{"成功", "ok.pdf", 10, modTime, false, true},
+		{"跳过", "skipped.pdf", 10, modTime, true, true},
+		{"失败", "failed.pdf", 10, modTime, false, true},
+		{"重试失败", "failed.pdf", 10, modTime, true, false},
+		{"大小变化", "ok.pdf", 11, modTime, false, false},
+		{"修改时间变化", "ok.pdf", 10, modTime.Add(time.Second), false, false},
+		{"没有记录", "new.pdf", 10, modTime, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := j.resumed(tt.path, tt.size, tt.modTime, tt.retryFailed); got != tt.want {
+				t.Fatalf("resumed = %v，want %v", got, tt.want)
+			}
+		})
+	}
+
+	var none *journal
+	if _, ok := none.resumed("ok.pdf", 10, modTime, false); ok {
+		t.Fatal("nil 检查点不应返回记录")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"net/http"
+)
+
+// 防止异常PDF（如PDF炸弹）耗尽资源的限制，0 表示不限制；提取时间由 fileTimeout 限制
+var (
+	// maxFileSize 单个PDF的最大字节数，由 max_file_size_mb 配置
+	maxFileSize int64 = 200 << 20
+	// maxPages 单个PDF的最大页数，由 max_pages 配置
+	maxPages = 10000
+	// maxOutputSize 单个文件输出内容的最大字节数，由 max_output_mb 配置
+	maxOutputSize int64 = 100 << 20
+	// maxBatchFiles 一次请求或一次 convert 命令最多转换的PDF数，由 max_batch_files 配置
+	maxBatchFiles = 1000
+)
+
+// readPDFData 读取PDF数据，超过 maxFileSize 时不再继续读取
+func readPDFData(r io.Reader) ([]byte, error) {
+	if maxFileSize <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxFileSize {
+		return nil, newConvertError(CodeLimitExceeded, "文件大小超过上限 %d MB", maxFileSize>>20)
+	}
+	return data, nil
+}
+
+// checkPageCount 检查文档页数
+func checkPageCount(n int) error {
+	if maxPages > 0 && n > maxPages {
+		return newConvertError(CodeLimitExceeded, "页数 %d 超过上限 %d", n, maxPages)
+	}
+	return nil
+}
+
+// checkOutputSize 检查生成的输出内容大小
+func checkOutputSize(content string) error {
+	if maxOutputSize > 0 && int64(len(content)) > maxOutputSize {
+		return newConvertError(CodeLimitExceeded, "输出内容超过上限 %d MB", maxOutputSize>>20)
+	}
+	return nil
+}
+
+// checkBatchSize 检查一批要转换的PDF数量
+func checkBatchSize(n int) error {
+	if maxBatchFiles > 0 && n > maxBatchFiles {
+		return newConvertError(CodeLimitExceeded, "文件数 %d 超过上限 %d", n, maxBatchFiles)
+	}
+	return nil
+}
+
+// capped 把到最后一页的区间截断为 maxPages+1 页，外部命令最多处理这么多页，
+// 多出的一页用于判断是否超过上限
+func (s pageSpan) capped() pageSpan {
+	if s.To == 0 && maxPages > 0 {
+		s.To = s.From + maxPages
+	}
+	return s
+}
+
+// limitErrorStatus 超出限制时返回413，否则返回 fallback
+func limitErrorStatus(err error, fallback int) int {
+	if errorCode(err) == CodeLimitExceeded {
+		return http.StatusRequestEntityTooLarge
+	}
+	return fallback
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// withLimits 在测试期间替换全局限制
+func withLimits(t *testing.T, fileSize int64, pages int, output int64, batch int) {
+	t.Helper()
+	s1, s2, s3, s4 := maxFileSize, maxPages, maxOutputSize, maxBatchFiles
+	t.Cleanup(func() { maxFileSize, maxPages, maxOutputSize, maxBatchFiles = s1, s2, s3, s4 })
+	maxFileSize, maxPages, maxOutputSize, maxBatchFiles = fileSize, pages, output, batch
+}
+
+func TestReadPDFData(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int64
+		size      int
+		wantLimit bool
+	}{
+		{name: "未超过上限", limit: 10, size: 10},
+		{name: "超过上限", limit: 10, size: 11, wantLimit: true},
+		{name: "不限制", limit: 0, size: 1 << 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withLimits(t, tt.limit, maxPages, maxOutputSize, maxBatchFiles)
+			data, err := readPDFData(strings.NewReader(strings.Repeat("x", tt.size)))
+			if tt.wantLimit {
+				if errorCode(err) != CodeLimitExceeded {
+					t.Fatalf("错误 = %v，want %s", err, CodeLimitExceeded)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data) != tt.size {
+				t.Errorf("读取 %d 字节，want %d", len(data), tt.size)
+			}
+		})
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int // 同时用作页数、输出字节数和文件数的上限
+		n         int
+		wantLimit bool
+	}{
+		{name: "等于上限", limit: 5, n: 5},
+		{name: "超过上限", limit: 5, n: 6, wantLimit: true},
+		{name: "不限制", limit: 0, n: 1 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withLimits(t, maxFileSize, tt.limit, int64(tt.limit), tt.limit)
+			checks := map[string]error{
+				"checkPageCount":  checkPageCount(tt.n),
+				"checkOutputSize": checkOutputSize(strings.Repeat("x", tt.n)),
+				"checkBatchSize":  checkBatchSize(tt.n),
+			}
+			for name, err := range checks {
+				if !tt.wantLimit {
+					if err != nil {
+						t.Errorf("%s(%d) = %v", name, tt.n, err)
+					}
+				} else if err == nil || errorCode(err) != CodeLimitExceeded {
+					t.Errorf("%s(%d) = %v，want %s", name, tt.n, err, CodeLimitExceeded)
+				}
+			}
+		})
+	}
+}
+
+func TestPageSpanCapped(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxPages int
+		span     pageSpan
+		want     pageSpan
+	}{
+		{name: "到最后一页的区间", maxPages: 100, span: pageSpan{From: 3}, want: pageSpan{From: 3, To: 103}},
+		{name: "有终点的区间不变", maxPages: 100, span: pageSpan{From: 3, To: 500}, want: pageSpan{From: 3, To: 500}},
+		{name: "不限制", maxPages: 0, span: pageSpan{From: 1}, want: pageSpan{From: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withLimits(t, maxFileSize, tt.maxPages, maxOutputSize, maxBatchFiles)
+			if got := tt.span.capped(); got != tt.want {
+				t.Errorf("capped() = %+v，want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitErrorStatus(t *testing.T) {
+	limit := newConvertError(CodeLimitExceeded, "页数 %d 超过上限 %d", 2, 1)
+	if got := limitErrorStatus(limit, http.StatusUnprocessableEntity); got != http.StatusRequestEntityTooLarge {
+		t.Errorf("超出限制时状态码 = %d，want 413", got)
+	}
+	if got := limitErrorStatus(errors.New("其他错误"), http.StatusUnprocessableEntity); got != http.StatusUnprocessableEntity {
+		t.Errorf("其他错误的状态码 = %d，want 422", got)
+	}
+}
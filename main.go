@@ -2,34 +2,120 @@ package main
 
 import (
 	"archive/zip"
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
-
-	"github.com/lu4p/unipdf/v3/extractor"
-	pdf "github.com/lu4p/unipdf/v3/model"
+	"time"
 )
 
+const usageText = `用法:
+  pdf2txt serve [选项]                  启动Web界面（默认）
+  pdf2txt convert <输入...> [-o 目录]   批量转换PDF文件或目录
+  pdf2txt watch <目录> -o <目录>        监视目录，自动转换新增或修改的PDF
+  pdf2txt config print [选项]           显示合并后的服务配置
+  pdf2txt cache stats|purge [选项]      查看或清空提取结果缓存
+
+使用 "pdf2txt <命令> -h" 查看命令选项
+`
+
 func main() {
+	if len(os.Args) < 2 {
+		os.Exit(runServe(nil))
+	}
+
+	switch os.Args[1] {
+	case "serve":
+		os.Exit(runServe(os.Args[2:]))
+	case "convert":
+		os.Exit(runConvert(os.Args[2:]))
+	case "watch":
+		os.Exit(runWatch(os.Args[2:]))
+	case "config":
+		os.Exit(runConfig(os.Args[2:]))
+	case "cache":
+		os.Exit(runCache(os.Args[2:]))
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usageText)
+	default:
+		fmt.Fprintf(os.Stderr, "未知命令: %s\n\n%s", os.Args[1], usageText)
+		os.Exit(2)
+	}
+}
+
+// runServe 启动Web服务器
+func runServe(args []string) int {
+	f := newServeFlags("serve")
+	if err := f.flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := f.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if err := cfg.apply(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	addr := cfg.addr()
+	if !authEnabled() && !isLoopbackAddr(addr) {
+		log.Printf("警告: 监听 %s 且未配置认证，任何能访问该地址的人都可以写入本机文件\n", addr)
+	}
+
 	http.HandleFunc("/", indexHandler)
 	http.HandleFunc("/api/upload-convert", uploadConvertHandler)
 	http.HandleFunc("/api/upload-save-local", uploadSaveLocalHandler)
+	http.HandleFunc("POST /api/jobs", createJobHandler)
+	http.HandleFunc("POST /api/convert-dir", convertDirHandler)
+	http.HandleFunc("GET /api/jobs/{id}", getJobHandler)
+	http.HandleFunc("GET /api/jobs/{id}/events", jobEventsHandler)
+	http.HandleFunc("GET /api/jobs/{id}/download", downloadJobHandler)
+	http.HandleFunc("GET /api/cache", cacheStatsHandler)
+	startJobJanitor()
+
+	log.Printf("Web服务器启动在 %s\n", addr)
+	if err := http.ListenAndServe(addr, requireAuth(http.DefaultServeMux)); err != nil {
+		log.Printf("Web服务器退出: %v\n", err)
+		return 1
+	}
+	return 0
+}
 
-	log.Println("Web服务器启动在 http://localhost:8089")
-	log.Fatal(http.ListenAndServe(":8089", nil))
+// isLoopbackAddr 判断监听地址是否只接受本机访问
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
 }
 
 func indexHandler(w http.ResponseWriter, r *http.Request) {
 	tmpl := template.Must(template.New("index").Parse(htmlTemplate))
-	tmpl.Execute(w, nil)
+	tmpl.Execute(w, map[string]interface{}{
+		"CSRFToken":    csrfToken,
+		"OutputDir":    defaultOutputDir,
+		"OCR":          ocrEnabled,
+		"OCRLanguages": ocrLanguages,
+		"InputRoots":   strings.Join(inputRoots, ", "),
+	})
 }
 
 func uploadConvertHandler(w http.ResponseWriter, r *http.Request) {
@@ -37,88 +123,144 @@ func uploadConvertHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-
-	// 解析multipart表单，限制最大内存为100MB
-	if err := r.ParseMultipartForm(100 << 20); err != nil {
-		http.Error(w, fmt.Sprintf("解析表单失败: %v", err), http.StatusBadRequest)
+	if !hasPermission(r, permZip) {
+		http.Error(w, permissionError(permZip), http.StatusForbidden)
 		return
 	}
 
-	files := r.MultipartForm.File["files"]
-	if len(files) == 0 {
-		http.Error(w, "没有上传文件", http.StatusBadRequest)
+	// 上传的PDF逐个写入临时目录，不在内存中缓存
+	tmpDir, err := os.MkdirTemp("", "pdf2txt-upload-*")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("创建临时目录失败: %v", err), http.StatusInternalServerError)
 		return
 	}
+	defer os.RemoveAll(tmpDir)
 
-	// 创建ZIP缓冲区
-	var zipBuffer bytes.Buffer
-	zipWriter := zip.NewWriter(&zipBuffer)
+	form, uploads, err := readMultipartStream(r, tmpDir)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("解析表单失败: %v", err), limitErrorStatus(err, http.StatusBadRequest))
+		return
+	}
+	if len(uploads) == 0 {
+		http.Error(w, "没有上传PDF文件", http.StatusBadRequest)
+		return
+	}
 
-	successCount := 0
-	failedCount := 0
+	opts, err := extractOptionsFromForm(form)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// 处理每个上传的PDF文件
-	for _, fileHeader := range files {
-		if !strings.HasSuffix(strings.ToLower(fileHeader.Filename), ".pdf") {
-			continue
-		}
+	collision, err := parseCollisionPolicy(formValue(form, "collision"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-		// 打开上传的文件
-		file, err := fileHeader.Open()
-		if err != nil {
-			log.Printf("打开文件失败 %s: %v\n", fileHeader.Filename, err)
-			failedCount++
-			continue
+	// 输入名优先使用相对路径以在ZIP中还原目录结构
+	paths := form.Value["paths"]
+	inputs := make([]string, len(uploads))
+	for i, upload := range uploads {
+		inputs[i] = upload.Filename
+		if upload.Index < len(paths) && paths[upload.Index] != "" {
+			inputs[i] = paths[upload.Index]
 		}
+	}
 
-		// 转换PDF为文本
-		text, err := convertPDFReaderToText(file)
-		file.Close()
+	// 转换前确定ZIP条目名，重名策略为 fail 时直接拒绝
+	entryNames, err := zipEntryNames(inputs, opts.Format, collision, opts.Meta)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 
-		if err != nil {
-			log.Printf("转换失败 %s: %v\n", fileHeader.Filename, err)
-			failedCount++
-			continue
-		}
+	// 并发转换，结果按上传顺序写入ZIP；先完成的文件等待前面的文件，
+	// 内存中最多只保留 workers 个文件的文本
+	// 第一个文件成功后才发送响应头，全部失败时仍可返回JSON错误
+	var zipWriter *zip.Writer
+	flusher, _ := w.(http.Flusher)
+	results := make([]FileResult, len(uploads))
+	forEachOrdered(workers, len(uploads), func(i int) func() {
+		var text string
+		text, results[i] = convertSpooledFile(r.Context(), uploads[i].Path, inputs[i], opts)
+		os.Remove(uploads[i].Path)
+		return func() {
+			result := &results[i]
+			if !result.OK() {
+				log.Printf("转换失败 %s: %s\n", result.Input, result.Error)
+				return
+			}
 
-		// 生成TXT文件名
-		txtFileName := strings.TrimSuffix(fileHeader.Filename, filepath.Ext(fileHeader.Filename)) + ".txt"
+			if zipWriter == nil {
+				w.Header().Set("Content-Type", "application/zip")
+				w.Header().Set("Content-Disposition", "attachment; filename=converted-texts.zip")
+				zipWriter = zip.NewWriter(w)
+			}
 
-		// 添加到ZIP
-		zipFile, err := zipWriter.Create(txtFileName)
-		if err != nil {
-			log.Printf("创建ZIP文件失败 %s: %v\n", txtFileName, err)
-			failedCount++
-			continue
-		}
+			txtFileName := entryNames[i]
+			if err := writeZipOutput(zipWriter, txtFileName, text, result.Meta); err != nil {
+				log.Printf("写入ZIP失败 %s: %v\n", txtFileName, err)
+				result.setError(newConvertError(CodeIO, "写入ZIP失败: %w", err))
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
 
-		if _, err := zipFile.Write([]byte(text)); err != nil {
-			log.Printf("写入ZIP失败 %s: %v\n", txtFileName, err)
-			failedCount++
-			continue
+			result.Output = txtFileName
+			log.Printf("转换成功: %s -> %s\n", result.Input, txtFileName)
 		}
+	})
 
-		successCount++
-		log.Printf("转换成功: %s\n", fileHeader.Filename)
+	report := newBatchReport(results)
+	if zipWriter == nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{
+			"success":      false,
+			"error":        "所有文件转换失败",
+			"successCount": report.Succeeded,
+			"failedCount":  report.Failed,
+			"results":      report.Files,
+		})
+		return
 	}
 
-	// 关闭ZIP writer
-	if err := zipWriter.Close(); err != nil {
-		http.Error(w, fmt.Sprintf("关闭ZIP失败: %v", err), http.StatusInternalServerError)
+	// 响应已经开始发送，之后的错误只能记录日志
+	if err := writeZipReport(zipWriter, results); err != nil {
+		log.Printf("写入清单失败: %v\n", err)
 		return
 	}
-
-	if successCount == 0 {
-		http.Error(w, "所有文件转换失败", http.StatusInternalServerError)
+	if err := zipWriter.Close(); err != nil {
+		log.Printf("关闭ZIP失败: %v\n", err)
 		return
 	}
 
-	// 返回ZIP文件
-	w.Header().Set("Content-Type", "application/zip")
-	w.Header().Set("Content-Disposition", "attachment; filename=converted-texts.zip")
-	w.Write(zipBuffer.Bytes())
+	log.Printf("转换完成: 成功 %d, 失败 %d\n", report.Succeeded, report.Failed)
+}
 
-	log.Printf("转换完成: 成功 %d, 失败 %d\n", successCount, failedCount)
+// writeZipOutput 在ZIP中写入转换结果，有元数据时同时写入 <name>.meta.json
+func writeZipOutput(zipWriter *zip.Writer, name, text string, meta *DocumentMeta) error {
+	if err := writeZipEntry(zipWriter, name, text); err != nil {
+		return err
+	}
+	if meta == nil {
+		return nil
+	}
+	content, err := renderMeta(meta)
+	if err != nil {
+		return err
+	}
+	return writeZipEntry(zipWriter, metaName(name), content)
+}
+
+// writeZipEntry 在ZIP中写入一个文本文件
+func writeZipEntry(zipWriter *zip.Writer, name, text string) error {
+	zipFile, err := zipWriter.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(zipFile, text)
+	return err
 }
 
 func uploadSaveLocalHandler(w http.ResponseWriter, r *http.Request) {
@@ -126,110 +268,90 @@ func uploadSaveLocalHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-
-	// 解析multipart表单，限制最大内存为100MB
-	if err := r.ParseMultipartForm(100 << 20); err != nil {
-		http.Error(w, fmt.Sprintf("解析表单失败: %v", err), http.StatusBadRequest)
+	if !hasPermission(r, permLocal) {
+		http.Error(w, permissionError(permLocal), http.StatusForbidden)
 		return
 	}
 
-	files := r.MultipartForm.File["files"]
-	paths := r.MultipartForm.Value["paths"]
-
-	if len(files) == 0 {
-		http.Error(w, "没有上传文件", http.StatusBadRequest)
+	// 上传的PDF逐个写入临时目录，不在内存中缓存
+	tmpDir, err := os.MkdirTemp("", "pdf2txt-upload-*")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("创建临时目录失败: %v", err), http.StatusInternalServerError)
 		return
 	}
+	defer os.RemoveAll(tmpDir)
 
-	// 确定输出目录
-	outputDir := ""
-	if dirs := r.MultipartForm.Value["outputDir"]; len(dirs) > 0 && dirs[0] != "" {
-		outputDir = dirs[0]
-	} else {
-		// 默认使用桌面的 PDF转换结果 文件夹
-		homeDir, _ := os.UserHomeDir()
-		outputDir = filepath.Join(homeDir, "Desktop", "PDF转换结果")
-	}
-
-	// 如果有相对路径信息，从第一个文件提取父目录名
-	if len(paths) > 0 && paths[0] != "" {
-		// 提取顶层文件夹名称
-		parts := strings.Split(paths[0], string(filepath.Separator))
-		if len(parts) > 0 {
-			topFolder := parts[0]
-			outputDir = filepath.Join(outputDir, topFolder)
-		}
+	form, uploads, err := readMultipartStream(r, tmpDir)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("解析表单失败: %v", err), limitErrorStatus(err, http.StatusBadRequest))
+		return
 	}
-
-	// 确保输出目录存在
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		http.Error(w, fmt.Sprintf("创建输出目录失败: %v", err), http.StatusInternalServerError)
+	if len(uploads) == 0 {
+		http.Error(w, "没有上传PDF文件", http.StatusBadRequest)
 		return
 	}
 
-	successCount := 0
-	failedCount := 0
-
-	// 处理每个上传的PDF文件
-	for i, fileHeader := range files {
-		if !strings.HasSuffix(strings.ToLower(fileHeader.Filename), ".pdf") {
-			continue
-		}
-
-		// 打开上传的文件
-		file, err := fileHeader.Open()
-		if err != nil {
-			log.Printf("打开文件失败 %s: %v\n", fileHeader.Filename, err)
-			failedCount++
-			continue
-		}
-
-		// 转换PDF为文本
-		text, err := convertPDFReaderToText(file)
-		file.Close()
+	opts, err := extractOptionsFromForm(form)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-		if err != nil {
-			log.Printf("转换失败 %s: %v\n", fileHeader.Filename, err)
-			failedCount++
-			continue
+	// 相对路径和输出目录都必须位于允许的根目录内
+	paths, err := sanitizePaths(form.Value["paths"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	target, err := localOutputTarget(form)
+	if err != nil {
+		http.Error(w, err.Error(), pathErrorStatus(err))
+		return
+	}
+	outputDir := target.path()
+	existing, err := parseExistingPolicy(formValue(form, "existing"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	modTimes := parseModTimes(form.Value["mtimes"])
+
+	// 上传文件的下标对应 paths 和 mtimes
+	filenames := make([]string, len(uploads))
+	relPaths := make([]string, len(uploads))
+	pdfModTimes := make([]time.Time, len(uploads))
+	for j, upload := range uploads {
+		filenames[j] = upload.Filename
+		if upload.Index < len(paths) {
+			relPaths[j] = paths[upload.Index]
 		}
-
-		// 确定输出文件路径
-		var outputPath string
-		if i < len(paths) && paths[i] != "" {
-			// 使用相对路径结构
-			relPath := paths[i]
-			// 移除顶层文件夹（已包含在 outputDir 中）
-			parts := strings.Split(relPath, string(filepath.Separator))
-			if len(parts) > 1 {
-				relPath = filepath.Join(parts[1:]...)
-			} else {
-				relPath = parts[0]
-			}
-			txtFileName := strings.TrimSuffix(relPath, filepath.Ext(relPath)) + ".txt"
-			outputPath = filepath.Join(outputDir, txtFileName)
-		} else {
-			txtFileName := strings.TrimSuffix(fileHeader.Filename, filepath.Ext(fileHeader.Filename)) + ".txt"
-			outputPath = filepath.Join(outputDir, txtFileName)
+		if upload.Index < len(modTimes) {
+			pdfModTimes[j] = modTimes[upload.Index]
 		}
+	}
+	if err := target.checkOutputs(filenames, relPaths, opts.Format); err != nil {
+		http.Error(w, err.Error(), pathErrorStatus(err))
+		return
+	}
 
-		// 确保子目录存在
-		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-			log.Printf("创建子目录失败 %s: %v\n", filepath.Dir(outputPath), err)
-			failedCount++
-			continue
-		}
+	// 并发转换并写入文件，结果按下标保存
+	results := make([]FileResult, len(uploads))
+	forEachParallel(workers, len(uploads), func(j int) {
+		results[j] = saveUploadedFile(r.Context(), uploads[j], relPaths[j], pdfModTimes[j], target, opts, existing)
+		os.Remove(uploads[j].Path)
+	})
 
-		// 写入文件
-		if err := os.WriteFile(outputPath, []byte(text), 0644); err != nil {
-			log.Printf("写入文件失败 %s: %v\n", outputPath, err)
-			failedCount++
-			continue
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			log.Printf("跳过（已有输出）: %s\n", result.Input)
+		case !result.OK():
+			log.Printf("转换失败 %s: %s\n", result.Input, result.Error)
+		default:
+			log.Printf("转换成功: %s -> %s\n", result.Input, result.Output)
 		}
-
-		successCount++
-		log.Printf("转换成功: %s -> %s\n", fileHeader.Filename, outputPath)
 	}
+	report := newBatchReport(results)
 
 	// 打开输出目录
 	if err := openFolder(outputDir); err != nil {
@@ -240,162 +362,220 @@ func uploadSaveLocalHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success":      true,
-		"successCount": successCount,
-		"failedCount":  failedCount,
+		"successCount": report.Succeeded,
+		"skippedCount": report.Skipped,
+		"failedCount":  report.Failed,
 		"outputPath":   outputDir,
+		"results":      report.Files,
 	})
 
-	log.Printf("本地保存完成: 成功 %d, 失败 %d, 输出目录: %s\n", successCount, failedCount, outputDir)
+	log.Printf("本地保存完成: 成功 %d, 跳过 %d, 失败 %d, 输出目录: %s\n", report.Succeeded, report.Skipped, report.Failed, outputDir)
 }
 
-// openFolder 打开指定文件夹
-func openFolder(path string) error {
-	var cmd *exec.Cmd
+// saveUploadedFile 转换上传的文件并写入输出目录，按 existing 策略跳过已有的输出
+func saveUploadedFile(ctx context.Context, upload uploadedFile, relPath string, modTime time.Time, target outputTarget, opts ExtractOptions, existing string) FileResult {
+	input := relPath
+	if input == "" {
+		input = upload.Filename
+	}
 
-	// 检测操作系统
-	output, _ := exec.Command("uname").Output()
-	platform := strings.ToLower(string(output))
+	if existing != existingOverwrite {
+		if rel, err := localOutputRel(upload.Filename, relPath, opts.Format); err == nil {
+			if info, output := target.stat(rel); skipExisting(existing, info, modTime) {
+				return FileResult{Input: input, Output: output, Skipped: true}
+			}
+		}
+	}
 
-	if strings.Contains(platform, "darwin") {
-		// macOS
-		cmd = exec.Command("open", path)
-	} else if strings.Contains(platform, "linux") {
-		// Linux
-		cmd = exec.Command("xdg-open", path)
-	} else {
-		// Windows
-		cmd = exec.Command("cmd", "/c", "start", path)
+	text, result := convertSpooledFile(ctx, upload.Path, input, opts)
+	if !result.OK() {
+		return result
 	}
 
-	return cmd.Start()
+	outputPath, err := writeLocalOutput(target, upload.Filename, relPath, text, opts.Format, result.Meta)
+	if err != nil {
+		result.setError(err)
+		return result
+	}
+
+	result.Output = outputPath
+	return result
 }
 
-// convertPDFReaderToText 从io.Reader读取PDF并转换为文本
-func convertPDFReaderToText(r io.Reader) (string, error) {
-	// 读取所有数据到内存
-	data, err := io.ReadAll(r)
+// writeLocalOutput 把转换结果写入输出目录，有元数据时同时写入 <name>.meta.json，返回输出文件的路径
+func writeLocalOutput(target outputTarget, filename, relPath, text, format string, meta *DocumentMeta) (string, error) {
+	rel, err := localOutputRel(filename, relPath, format)
 	if err != nil {
-		return "", fmt.Errorf("读取PDF数据失败: %w", err)
+		return "", err
 	}
-
-	// 首先尝试使用unipdf
-	text, err := convertWithUnipdf(data)
-	if err == nil {
-		return text, nil
+	outputPath, err := target.writeFile(rel, text)
+	if err != nil || meta == nil {
+		return outputPath, err
 	}
 
-	// 如果unipdf失败，尝试使用pdftotext
-	log.Printf("unipdf转换失败: %v，尝试使用pdftotext", err)
-	text, err = convertWithPdftotext(data)
-	if err == nil {
-		return text, nil
+	content, err := renderMeta(meta)
+	if err != nil {
+		return "", newConvertError(CodeUnknown, "生成元数据失败: %w", err)
 	}
-
-	return "", fmt.Errorf("所有转换方法都失败了: unipdf和pdftotext都不可用")
+	if _, err := target.writeFile(metaName(rel), content); err != nil {
+		return "", err
+	}
+	return outputPath, nil
 }
 
-// convertWithUnipdf 使用unipdf库转换PDF
-func convertWithUnipdf(data []byte) (string, error) {
-	// 创建bytes.Reader以支持Seek
-	reader := bytes.NewReader(data)
-
-	// 创建PDF阅读器
-	pdfReader, err := pdf.NewPdfReader(reader)
-	if err != nil {
-		return "", fmt.Errorf("创建PDF阅读器失败: %w", err)
+// pathErrorStatus 路径不安全时返回400，其他错误返回500
+func pathErrorStatus(err error) int {
+	if errorCode(err) == CodeUnsafePath {
+		return http.StatusBadRequest
 	}
+	return http.StatusInternalServerError
+}
 
-	// 获取页数
-	numPages, err := pdfReader.GetNumPages()
-	if err != nil {
-		return "", fmt.Errorf("获取页数失败: %w", err)
+// formValue 返回表单字段的第一个值
+func formValue(form *multipart.Form, key string) string {
+	if v := form.Value[key]; len(v) > 0 {
+		return v[0]
 	}
+	return ""
+}
 
-	// 提取所有页面的文本
-	var allText strings.Builder
-	for i := 1; i <= numPages; i++ {
-		page, err := pdfReader.GetPage(i)
+// extractOptionsFromForm 从表单中读取提取参数
+func extractOptionsFromForm(form *multipart.Form) (ExtractOptions, error) {
+	var opts ExtractOptions
+	if v := form.Value["backends"]; len(v) > 0 && v[0] != "" {
+		backends, err := parseBackends(v[0])
 		if err != nil {
-			return "", fmt.Errorf("获取第%d页失败: %w", i, err)
+			return opts, err
 		}
-
-		ex, err := extractor.New(page)
-		if err != nil {
-			return "", fmt.Errorf("创建提取器失败（第%d页）: %w", i, err)
+		opts.Backends = backends
+	}
+	opts.Passwords = form.Value["password"]
+	format, err := parseFormat(formValue(form, "format"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Format = format
+	if opts.Positions, err = parsePositions(formValue(form, "positions")); err != nil {
+		return opts, err
+	}
+	if v := formValue(form, "meta"); v != "" {
+		if opts.Meta, err = strconv.ParseBool(v); err != nil {
+			return opts, fmt.Errorf("无效的 meta 参数: %s", v)
 		}
-
-		text, err := ex.ExtractText()
+	}
+	opts.OCR, opts.OCRLanguages = ocrEnabled, ocrLanguages
+	if v := formValue(form, "ocr"); v != "" {
+		if opts.OCR, err = strconv.ParseBool(v); err != nil {
+			return opts, fmt.Errorf("无效的 ocr 参数: %s", v)
+		}
+	}
+	if v := strings.TrimSpace(formValue(form, "ocrLang")); v != "" {
+		opts.OCRLanguages = v
+	}
+	if v := form.Value["pages"]; len(v) > 0 {
+		pageRange, err := parsePageRange(v[0])
 		if err != nil {
-			return "", fmt.Errorf("提取文本失败（第%d页）: %w", i, err)
+			return opts, err
 		}
-
-		allText.WriteString(text)
-		allText.WriteString("\n")
+		opts.PageRange = pageRange
 	}
-
-	return allText.String(), nil
+	return opts, nil
 }
 
-// convertWithPdftotext 使用pdftotext命令行工具转换PDF
-func convertWithPdftotext(data []byte) (string, error) {
-	// 检查pdftotext是否可用
-	if _, err := exec.LookPath("pdftotext"); err != nil {
-		return "", fmt.Errorf("pdftotext命令不可用，请安装poppler-utils")
+// openFolder 打开指定文件夹
+func openFolder(path string) error {
+	var cmd *exec.Cmd
+
+	// 检测操作系统
+	output, _ := exec.Command("uname").Output()
+	platform := strings.ToLower(string(output))
+
+	if strings.Contains(platform, "darwin") {
+		// macOS
+		cmd = exec.Command("open", path)
+	} else if strings.Contains(platform, "linux") {
+		// Linux
+		cmd = exec.Command("xdg-open", path)
+	} else {
+		// Windows
+		cmd = exec.Command("cmd", "/c", "start", path)
 	}
 
-	// 创建临时文件
-	tmpFile, err := os.CreateTemp("", "pdf2txt-*.pdf")
+	return cmd.Start()
+}
+
+// convertPDFReaderToText 从io.Reader读取PDF并转换为文本
+func convertPDFReaderToText(ctx context.Context, r io.Reader, opts ExtractOptions) (*ExtractResult, error) {
+	// 读取所有数据到内存，超过 maxFileSize 时拒绝
+	data, err := readPDFData(r)
 	if err != nil {
-		return "", fmt.Errorf("创建临时文件失败: %w", err)
+		if errorCode(err) == CodeLimitExceeded {
+			return nil, err
+		}
+		return nil, newConvertError(CodeIO, "读取PDF数据失败: %w", err)
 	}
-	tmpPath := tmpFile.Name()
-	defer os.Remove(tmpPath)
 
-	// 写入PDF数据
-	if _, err := tmpFile.Write(data); err != nil {
-		tmpFile.Close()
-		return "", fmt.Errorf("写入临时文件失败: %w", err)
+	// 相同的数据和参数直接使用缓存的结果
+	var key string
+	if cacheEnabled {
+		key = cacheKey(data, opts)
+		if result, ok := loadCached(key); ok {
+			// 缓存写入后页数上限可能已经调低
+			if err := checkPageCount(max(result.TotalPages, len(result.Pages)+len(result.Failed))); err != nil {
+				return nil, err
+			}
+			return result, nil
+		}
 	}
-	tmpFile.Close()
 
-	// 执行pdftotext命令
-	cmd := exec.Command("pdftotext", "-layout", tmpPath, "-")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("pdftotext执行失败: %w", err)
+	// 不完整的结果不缓存，以免调高期限或安装后端后仍返回旧结果
+	result, err := extractText(ctx, data, opts)
+	if err == nil && key != "" && !result.incomplete {
+		storeCached(key, result)
 	}
-
-	return string(output), nil
+	return result, err
 }
 
 // convertPDFToText 将单个PDF文件转换为文本文件
-func convertPDFToText(pdfPath string, outputDir string) error {
+func convertPDFToText(ctx context.Context, pdfPath string, outputDir string, opts ExtractOptions) error {
 	// 读取PDF文件
-	data, err := os.ReadFile(pdfPath)
+	file, err := os.Open(pdfPath)
+	if err != nil {
+		return newConvertError(CodeIO, "读取PDF文件失败: %w", err)
+	}
+	result, err := convertPDFReaderToText(ctx, file, opts)
+	file.Close()
 	if err != nil {
-		return fmt.Errorf("读取PDF文件失败: %w", err)
+		return err
 	}
 
-	// 首先尝试使用unipdf
-	text, err := convertWithUnipdf(data)
+	content, err := renderOutput(filepath.Base(pdfPath), result, opts)
 	if err != nil {
-		// 如果unipdf失败，尝试使用pdftotext
-		log.Printf("unipdf转换失败: %v，尝试使用pdftotext", err)
-		text, err = convertWithPdftotext(data)
-		if err != nil {
-			return fmt.Errorf("所有转换方法都失败了: %w", err)
-		}
+		return newConvertError(CodeUnknown, "生成输出失败: %w", err)
+	}
+	if err := checkOutputSize(content); err != nil {
+		return err
 	}
 
 	// 生成输出文件名
-	baseName := filepath.Base(pdfPath)
-	txtFileName := strings.TrimSuffix(baseName, filepath.Ext(baseName)) + ".txt"
+	txtFileName := outputName(filepath.Base(pdfPath), opts.Format)
 	outputPath := filepath.Join(outputDir, txtFileName)
 
 	// 写入文件
-	err = os.WriteFile(outputPath, []byte(text), 0644)
+	err = writeFileAtomic(outputPath, []byte(content))
 	if err != nil {
-		return fmt.Errorf("写入TXT文件失败: %w", err)
+		return newConvertError(CodeIO, "写入TXT文件失败: %w", err)
+	}
+
+	if result.Meta != nil {
+		result.Meta.Source = filepath.Base(pdfPath)
+		meta, err := renderMeta(result.Meta)
+		if err != nil {
+			return newConvertError(CodeUnknown, "生成元数据失败: %w", err)
+		}
+		if err := writeFileAtomic(filepath.Join(outputDir, metaName(txtFileName)), []byte(meta)); err != nil {
+			return newConvertError(CodeIO, "写入元数据失败: %w", err)
+		}
 	}
 
 	return nil
@@ -407,6 +587,7 @@ const htmlTemplate = `
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="csrf-token" content="{{.CSRFToken}}">
     <title>PDF转TXT批量转换工具</title>
     <style>
         * {
@@ -532,6 +713,14 @@ const htmlTemplate = `
             font-size: 14px;
             font-family: monospace;
         }
+        .input-group select {
+            width: 100%;
+            padding: 12px 15px;
+            border: 1px solid #e0e0e0;
+            border-radius: 6px;
+            font-size: 14px;
+            background: white;
+        }
         .input-group input:focus {
             outline: none;
             border-color: #667eea;
@@ -592,27 +781,83 @@ const htmlTemplate = `
             padding: 5px 0;
             border-bottom: 1px solid #e0e0e0;
         }
-        .loading {
+        .progress-panel {
             display: none;
-            text-align: center;
-            padding: 20px;
-            color: #667eea;
+            margin-top: 10px;
         }
-        .loading.show {
+        .progress-panel.show {
             display: block;
         }
-        .spinner {
-            border: 3px solid #f3f3f3;
-            border-top: 3px solid #667eea;
-            border-radius: 50%;
-            width: 40px;
-            height: 40px;
-            animation: spin 1s linear infinite;
-            margin: 0 auto 15px;
+        .progress-info {
+            display: flex;
+            justify-content: space-between;
+            font-size: 14px;
+            color: #555;
+            margin-bottom: 8px;
         }
-        @keyframes spin {
-            0% { transform: rotate(0deg); }
-            100% { transform: rotate(360deg); }
+        .progress {
+            height: 12px;
+            background: #f0f0f0;
+            border-radius: 6px;
+            overflow: hidden;
+            margin-bottom: 15px;
+        }
+        .progress-bar {
+            height: 100%;
+            width: 0;
+            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+            transition: width 0.3s;
+        }
+        .file-rows {
+            list-style: none;
+            border: 1px solid #e0e0e0;
+            border-radius: 6px;
+            max-height: 300px;
+            overflow-y: auto;
+            font-size: 13px;
+        }
+        .file-rows li {
+            display: flex;
+            align-items: center;
+            gap: 10px;
+            padding: 8px 12px;
+            border-bottom: 1px solid #f0f0f0;
+        }
+        .file-rows li:last-child {
+            border-bottom: none;
+        }
+        .file-rows .name {
+            flex: 1;
+            font-family: monospace;
+            word-break: break-all;
+        }
+        .file-rows .detail {
+            color: #999;
+            font-size: 12px;
+        }
+        .badge {
+            padding: 2px 8px;
+            border-radius: 10px;
+            font-size: 12px;
+            white-space: nowrap;
+            background: #f0f0f0;
+            color: #666;
+        }
+        .badge.running {
+            background: #e0e7ff;
+            color: #667eea;
+        }
+        .badge.success {
+            background: #d1fae5;
+            color: #10b981;
+        }
+        .badge.failed {
+            background: #fee2e2;
+            color: #ef4444;
+        }
+        .badge.skipped {
+            background: #fef3c7;
+            color: #d97706;
         }
     </style>
 </head>
@@ -634,6 +879,11 @@ const htmlTemplate = `
                     <div style="font-weight: 600; margin-bottom: 8px;">已选择 <span id="pdfCount">0</span> 个PDF文件</div>
                     <div style="font-size: 13px; color: #666; max-height: 150px; overflow-y: auto;" id="fileList"></div>
                 </div>
+                {{if .InputRoots}}
+                <button class="btn" onclick="selectServerDir()" style="margin-bottom: 20px;">
+                    🖥️ 直接转换服务器上的文件夹（无需上传）
+                </button>
+                {{end}}
             </div>
 
             <div class="section" id="outputSection" style="display: none;">
@@ -647,13 +897,89 @@ const htmlTemplate = `
                         <input type="radio" name="outputMode" value="local" onchange="toggleOutputMode()">
                         <span style="margin-left: 8px;">保存到本地文件夹并自动打开</span>
                     </label>
+                    {{if .InputRoots}}
+                    <label style="display: block; margin-top: 10px; cursor: pointer;">
+                        <input type="radio" name="outputMode" value="server" onchange="toggleOutputMode()">
+                        <span style="margin-left: 8px;">转换服务器上的文件夹</span>
+                    </label>
+                    {{end}}
+                </div>
+                <div class="input-group">
+                    <label>输出格式</label>
+                    <select id="outputFormat" onchange="toggleFormat()">
+                        <option value="txt" selected>纯文本（.txt）</option>
+                        <option value="md">Markdown（.md，识别标题和列表）</option>
+                        <option value="json">结构化JSON（.json，含每页文本）</option>
+                    </select>
+                    <label id="positionsOption" style="display: none; margin-top: 8px; cursor: pointer;">
+                        <input type="checkbox" id="includePositions">
+                        <span style="margin-left: 8px;">包含行和单词的坐标、字体信息</span>
+                    </label>
+                    <label style="display: block; margin-top: 8px; cursor: pointer;">
+                        <input type="checkbox" id="includeMeta">
+                        <span style="margin-left: 8px;">同时生成元数据文件（.meta.json，含标题、作者、创建日期等）</span>
+                    </label>
+                </div>
+                <div class="input-group">
+                    <label>页码范围（可选，如 1-3,10,20-，留空则提取全部页面）</label>
+                    <input type="text" id="pageRange" placeholder="全部页面">
+                </div>
+                <div class="input-group">
+                    <label>PDF密码（可选，用于打开加密文件）</label>
+                    <input type="password" id="pdfPassword" placeholder="留空则只尝试空密码" autocomplete="off">
+                </div>
+                <div class="input-group">
+                    <label style="cursor: pointer;">
+                        <input type="checkbox" id="enableOCR" {{if .OCR}}checked{{end}}>
+                        <span style="margin-left: 8px;">对扫描件启用OCR（需要服务器安装 tesseract）</span>
+                    </label>
+                    <input type="text" id="ocrLang" value="{{.OCRLanguages}}" placeholder="识别语言，如 chi_sim+eng" style="margin-top: 8px;">
+                </div>
+                <div class="input-group" id="zipOutputOptions">
+                    <label>ZIP中文件重名时</label>
+                    <select id="collisionPolicy">
+                        <option value="suffix" selected>追加序号，如 report (2).txt</option>
+                        <option value="hash">追加源文件路径的短哈希</option>
+                        <option value="fail">拒绝转换</option>
+                    </select>
+                </div>
+                {{if .InputRoots}}
+                <div id="serverOutputOptions" style="display: none;">
+                    <div class="input-group">
+                        <label>服务器上的输入文件夹（必须位于 {{.InputRoots}} 内）</label>
+                        <input type="text" id="serverInputDir" placeholder="绝对路径，如 {{.InputRoots}}">
+                    </div>
+                    <div class="input-group">
+                        <label>结果保存位置</label>
+                        <select id="serverPlacement" onchange="toggleOutputMode()">
+                            <option value="mirror" selected>输出文件夹中，保持原有目录结构</option>
+                            <option value="beside">与源文件放在同一文件夹</option>
+                        </select>
+                    </div>
+                    <div class="input-group">
+                        <label>上次转换中断时</label>
+                        <select id="serverResume">
+                            <option value="" selected>从头开始</option>
+                            <option value="resume">从中断处继续，跳过已处理的文件</option>
+                            <option value="retry">从中断处继续，并重试失败的文件</option>
+                        </select>
+                    </div>
                 </div>
+                {{end}}
                 <div id="localOutputOptions" style="display: none;">
                     <div class="input-group">
-                        <label>选择输出文件夹（留空则保存在桌面的 PDF转换结果 文件夹）</label>
-                        <input type="text" id="localOutputDir" placeholder="留空使用默认位置：~/Desktop/PDF转换结果">
+                        <label>选择输出文件夹（留空则保存在默认输出目录）</label>
+                        <input type="text" id="localOutputDir" placeholder="留空使用默认位置：{{.OutputDir}}">
                     </div>
                 </div>
+                <div class="input-group" id="existingOptions" style="display: none;">
+                    <label>输出文件已存在时</label>
+                    <select id="existingPolicy">
+                        <option value="overwrite" selected>重新转换并覆盖</option>
+                        <option value="skip">跳过，保留已有的文件</option>
+                        <option value="newer">仅在PDF比已有文件新时重新转换</option>
+                    </select>
+                </div>
             </div>
 
             <div class="section">
@@ -662,9 +988,13 @@ const htmlTemplate = `
                 </button>
             </div>
 
-            <div class="loading" id="loading">
-                <div class="spinner"></div>
-                <div>正在转换中，请稍候...</div>
+            <div class="progress-panel" id="progressPanel">
+                <div class="progress-info">
+                    <span id="progressStatus">正在上传...</span>
+                    <span id="progressText">0 / 0</span>
+                </div>
+                <div class="progress"><div class="progress-bar" id="progressBar"></div></div>
+                <ul class="file-rows" id="fileRows"></ul>
             </div>
 
             <div class="results" id="results">
@@ -686,8 +1016,27 @@ const htmlTemplate = `
 
         function toggleOutputMode() {
             const mode = document.querySelector('input[name="outputMode"]:checked').value;
-            const localOptions = document.getElementById('localOutputOptions');
-            localOptions.style.display = mode === 'local' ? 'block' : 'none';
+            const serverOptions = document.getElementById('serverOutputOptions');
+            const mirror = mode === 'server' && document.getElementById('serverPlacement').value === 'mirror';
+            document.getElementById('localOutputOptions').style.display = mode === 'local' || mirror ? 'block' : 'none';
+            document.getElementById('zipOutputOptions').style.display = mode === 'download' ? 'block' : 'none';
+            document.getElementById('existingOptions').style.display = mode === 'download' ? 'none' : 'block';
+            if (serverOptions) {
+                serverOptions.style.display = mode === 'server' ? 'block' : 'none';
+            }
+        }
+
+        function selectServerDir() {
+            document.querySelector('input[name="outputMode"][value="server"]').checked = true;
+            toggleOutputMode();
+            document.getElementById('outputSection').style.display = 'block';
+            document.getElementById('processBtn').style.display = 'block';
+            document.getElementById('serverInputDir').focus();
+        }
+
+        function toggleFormat() {
+            const format = document.getElementById('outputFormat').value;
+            document.getElementById('positionsOption').style.display = format === 'json' ? 'block' : 'none';
         }
 
         function handleFolderSelect() {
@@ -715,105 +1064,253 @@ const htmlTemplate = `
             document.getElementById('processBtn').style.display = 'block';
         }
 
-        function startProcess() {
+        const statusLabels = {
+            pending: '等待中',
+            running: '转换中',
+            success: '成功',
+            skipped: '已跳过',
+            failed: '失败'
+        };
+
+        async function startProcess() {
             const mode = document.querySelector('input[name="outputMode"]:checked').value;
-            if (mode === 'download') {
-                uploadAndConvert();
+            const formData = new FormData();
+            if (mode === 'server') {
+                const inputDir = document.getElementById('serverInputDir').value.trim();
+                if (!inputDir) {
+                    alert('请输入服务器上的文件夹路径');
+                    return;
+                }
+                formData.append('inputDir', inputDir);
+                formData.append('output', document.getElementById('serverPlacement').value);
+                const resume = document.getElementById('serverResume').value;
+                if (resume) {
+                    formData.append(resume === 'retry' ? 'retryFailed' : 'resume', 'true');
+                }
             } else {
-                uploadAndSaveLocal();
+                if (selectedFiles.length === 0) {
+                    alert('请先选择包含PDF文件的文件夹');
+                    return;
+                }
+                formData.append('mode', mode === 'download' ? 'zip' : 'local');
+                selectedFiles.forEach(file => {
+                    formData.append('files', file);
+                    // 发送文件的相对路径，用于在服务器端还原目录结构
+                    formData.append('paths', file.webkitRelativePath || file.name);
+                    // 发送文件的修改时间，用于判断已有的输出是否过期
+                    formData.append('mtimes', file.lastModified);
+                });
             }
-        }
 
-        async function uploadAndConvert() {
-            if (selectedFiles.length === 0) {
-                alert('请先选择包含PDF文件的文件夹');
-                return;
+            const format = document.getElementById('outputFormat').value;
+            formData.append('format', format);
+            if (format === 'json' && document.getElementById('includePositions').checked) {
+                formData.append('positions', 'all');
             }
 
-            const formData = new FormData();
-            selectedFiles.forEach(file => {
-                formData.append('files', file);
-            });
+            const pageRange = document.getElementById('pageRange').value.trim();
+            if (pageRange) {
+                formData.append('pages', pageRange);
+            }
+
+            if (document.getElementById('includeMeta').checked) {
+                formData.append('meta', 'true');
+            }
+            formData.append('ocr', document.getElementById('enableOCR').checked ? 'true' : 'false');
+            const ocrLang = document.getElementById('ocrLang').value.trim();
+            if (ocrLang) {
+                formData.append('ocrLang', ocrLang);
+            }
 
-            document.getElementById('processBtn').disabled = true;
-            document.getElementById('loading').classList.add('show');
+            const password = document.getElementById('pdfPassword').value;
+            if (password) {
+                formData.append('password', password);
+            }
+
+            if (mode === 'download') {
+                formData.append('collision', document.getElementById('collisionPolicy').value);
+            } else {
+                formData.append('existing', document.getElementById('existingPolicy').value);
+            }
+
+            if (document.getElementById('localOutputOptions').style.display !== 'none') {
+                const outputDir = document.getElementById('localOutputDir').value;
+                if (outputDir) {
+                    formData.append('outputDir', outputDir);
+                }
+            }
+
+            setBusy(true);
             document.getElementById('results').classList.remove('show');
+            document.getElementById('fileRows').innerHTML = '';
+            document.getElementById('progressStatus').textContent = mode === 'server' ? '正在扫描文件夹...' : '正在上传...';
+            updateProgress(0, mode === 'server' ? 0 : selectedFiles.length);
 
             try {
-                const response = await fetch('/api/upload-convert', {
+                const response = await fetch(mode === 'server' ? '/api/convert-dir' : '/api/jobs', {
                     method: 'POST',
+                    headers: {
+                        'X-CSRF-Token': document.querySelector('meta[name="csrf-token"]').content
+                    },
                     body: formData
                 });
 
+                const job = await response.json();
+
                 if (!response.ok) {
-                    throw new Error('转换失败: ' + response.statusText);
+                    throw new Error(job.error || '创建任务失败');
                 }
 
-                const blob = await response.blob();
-                const url = window.URL.createObjectURL(blob);
-                const a = document.createElement('a');
-                a.href = url;
-                a.download = 'converted-texts.zip';
-                document.body.appendChild(a);
-                a.click();
-                window.URL.revokeObjectURL(url);
-                document.body.removeChild(a);
-
-                alert('转换完成！ZIP文件已保存到浏览器下载文件夹。\n\n提示：通常位于 ~/Downloads/ 目录');
+                watchJob(job);
             } catch (error) {
                 alert('转换失败: ' + error.message);
-            } finally {
-                document.getElementById('processBtn').disabled = false;
-                document.getElementById('loading').classList.remove('show');
+                setBusy(false);
             }
         }
 
-        async function uploadAndSaveLocal() {
-            if (selectedFiles.length === 0) {
-                alert('请先选择包含PDF文件的文件夹');
-                return;
+        function setBusy(busy) {
+            document.getElementById('processBtn').disabled = busy;
+            if (busy) {
+                document.getElementById('progressPanel').classList.add('show');
             }
+        }
 
-            const formData = new FormData();
-            selectedFiles.forEach(file => {
-                formData.append('files', file);
-                // 发送文件的相对路径，用于在服务器端还原目录结构
-                formData.append('paths', file.webkitRelativePath || file.name);
+        function watchJob(job) {
+            renderRows(job.files);
+            updateProgress(job.completed, job.total);
+            document.getElementById('progressStatus').textContent = '正在转换...';
+
+            const source = new EventSource('/api/jobs/' + job.id + '/events');
+            source.addEventListener('snapshot', event => {
+                const snapshot = JSON.parse(event.data);
+                renderRows(snapshot.files);
+                updateProgress(snapshot.completed, snapshot.total);
+            });
+            source.addEventListener('file', event => {
+                const data = JSON.parse(event.data);
+                updateRow(data.index, data.file);
+                updateProgress(data.completed, data.total);
+            });
+            source.addEventListener('done', event => {
+                source.close();
+                finishJob(JSON.parse(event.data));
             });
+            source.onerror = () => {
+                // 连接中断时改为轮询任务状态
+                source.close();
+                pollJob(job.id);
+            };
+        }
 
-            const outputDir = document.getElementById('localOutputDir').value;
-            if (outputDir) {
-                formData.append('outputDir', outputDir);
-            }
+        function pollJob(id) {
+            const timer = setInterval(async () => {
+                try {
+                    const response = await fetch('/api/jobs/' + id);
+                    const snapshot = await response.json();
+                    if (!response.ok) {
+                        throw new Error(snapshot.error || '查询任务失败');
+                    }
+                    renderRows(snapshot.files);
+                    updateProgress(snapshot.completed, snapshot.total);
+                    if (snapshot.status === 'done' || snapshot.status === 'failed') {
+                        clearInterval(timer);
+                        finishJob(snapshot);
+                    }
+                } catch (error) {
+                    clearInterval(timer);
+                    alert('查询进度失败: ' + error.message);
+                    setBusy(false);
+                }
+            }, 1000);
+        }
 
-            document.getElementById('processBtn').disabled = true;
-            document.getElementById('loading').classList.add('show');
-            document.getElementById('results').classList.remove('show');
+        function updateProgress(completed, total) {
+            const percent = total > 0 ? Math.round(completed * 100 / total) : 0;
+            document.getElementById('progressBar').style.width = percent + '%';
+            document.getElementById('progressText').textContent = completed + ' / ' + total + '（' + percent + '%）';
+        }
 
-            try {
-                const response = await fetch('/api/upload-save-local', {
-                    method: 'POST',
-                    body: formData
-                });
+        function renderRows(files) {
+            const rows = document.getElementById('fileRows');
+            rows.innerHTML = '';
+            files.forEach(file => {
+                const li = document.createElement('li');
+                li.innerHTML = '<span class="name"></span><span class="detail"></span><span class="badge"></span>';
+                rows.appendChild(li);
+                fillRow(li, file);
+            });
+        }
 
-                const result = await response.json();
+        function updateRow(index, file) {
+            const li = document.getElementById('fileRows').children[index];
+            if (li) {
+                fillRow(li, file);
+            }
+        }
 
-                if (!response.ok) {
-                    throw new Error(result.error || '转换失败');
+        function fillRow(li, file) {
+            li.querySelector('.name').textContent = file.path || file.name;
+            li.querySelector('.detail').textContent = file.error || '';
+            const badge = li.querySelector('.badge');
+            badge.className = 'badge ' + file.status;
+            badge.textContent = statusLabels[file.status] || file.status;
+        }
+
+        function finishJob(job) {
+            setBusy(false);
+            document.getElementById('progressStatus').textContent = job.status === 'done' ? '转换完成' : '转换失败';
+            showResults(job);
+
+            if (job.mode === 'zip') {
+                if (job.downloadUrl) {
+                    const a = document.createElement('a');
+                    a.href = job.downloadUrl;
+                    a.download = 'converted-texts.zip';
+                    document.body.appendChild(a);
+                    a.click();
+                    document.body.removeChild(a);
+
+                    alert('转换完成！ZIP文件已保存到浏览器下载文件夹。\n\n提示：通常位于 ~/Downloads/ 目录');
+                } else {
+                    alert('转换失败: 所有文件转换失败');
                 }
+                return;
+            }
 
-                alert('转换完成！\n\n' +
-                      '成功: ' + result.successCount + ' 个文件\n' +
-                      '失败: ' + result.failedCount + ' 个文件\n\n' +
-                      '文件已保存到: ' + result.outputPath + '\n\n' +
-                      '文件夹将自动打开...');
+            alert('转换完成！\n\n' +
+                  '成功: ' + job.successCount + ' 个文件\n' +
+                  (job.skippedCount ? '跳过: ' + job.skippedCount + ' 个文件（已有输出）\n' : '') +
+                  '失败: ' + job.failedCount + ' 个文件\n\n' +
+                  '文件已保存到: ' + job.outputPath + '\n\n' +
+                  '文件夹将自动打开...');
+        }
 
-            } catch (error) {
-                alert('转换失败: ' + error.message);
-            } finally {
-                document.getElementById('processBtn').disabled = false;
-                document.getElementById('loading').classList.remove('show');
-            }
+        function showResults(job) {
+            const successList = document.getElementById('successList');
+            const failedList = document.getElementById('failedList');
+            successList.innerHTML = '';
+            failedList.innerHTML = '';
+
+            job.files.forEach(file => {
+                const li = document.createElement('li');
+                if (file.status === 'success') {
+                    const ocr = file.ocrPages ? '，OCR 页 ' + file.ocrPages.join(',') : '';
+                    const skipped = file.failedPages ? '，跳过第 ' + file.failedPages.join(',') + ' 页' : '';
+                    li.textContent = file.input + ' → ' + file.output +
+                        '（' + file.backend + '，' + file.pages + ' 页' + ocr + skipped + '，' + file.durationMs + ' ms）';
+                    successList.appendChild(li);
+                } else if (file.status === 'skipped') {
+                    li.textContent = file.input + ' → ' + file.output + '（已有输出，已跳过）';
+                    successList.appendChild(li);
+                } else if (file.status === 'failed') {
+                    li.textContent = file.input + ': [' + file.errorCode + '] ' + file.error;
+                    failedList.appendChild(li);
+                }
+            });
+
+            document.getElementById('successCount').textContent = job.successCount + (job.skippedCount || 0);
+            document.getElementById('failedCount').textContent = job.failedCount;
+            document.getElementById('results').classList.add('show');
         }
 
     </script>
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// bulletPattern 无序列表项，如 "• item"、"- item"
+	bulletPattern = regexp.MustCompile(`^[•·▪‣◦●○■□\-\*–—]\s+(.*)$`)
+	// numberedPattern 有序列表项，如 "1. item"、"2) item"、"3、item"
+	numberedPattern = regexp.MustCompile(`^(\d{1,3})(?:[.)]\s+|、\s*)(.+)$`)
+	// letteredPattern 字母序号的列表项，如 "a. item"、"B) item"
+	letteredPattern = regexp.MustCompile(`^([a-zA-Z])[.)]\s+(.+)$`)
+)
+
+// mdLine Markdown排版使用的行信息
+type mdLine struct {
+	text   string
+	size   float64 // 字号，无坐标信息时为0
+	bold   bool
+	llx    float64
+	lly    float64
+	ury    float64
+	hasBox bool
+}
+
+// mdBlock 已识别的Markdown块
+type mdBlock struct {
+	text string
+	list bool // 列表项之间不插入空行
+}
+
+// renderMarkdown 把提取结果转换为Markdown：
+// 根据字号和粗体识别标题，保留列表，合并换行的段落，并为每页输出锚点。
+func renderMarkdown(result *ExtractResult) string {
+	pages := make([][]mdLine, len(result.Pages))
+	for i, p := range result.Pages {
+		pages[i] = markdownLines(p)
+	}
+
+	body := bodyFontSize(pages)
+	levels := headingLevels(pages, body)
+
+	var b strings.Builder
+	for i, p := range result.Pages {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "<a id=\"page-%d\"></a>\n\n", p.Number)
+		writeBlocks(&b, markdownBlocks(pages[i], body, levels))
+	}
+	return b.String()
+}
+
+// markdownLines 从页面中取出行；没有坐标信息时按文本换行拆分
+func markdownLines(p Page) []mdLine {
+	if len(p.Lines) > 0 {
+		lines := make([]mdLine, len(p.Lines))
+		for i, l := range p.Lines {
+			lines[i] = mdLine{
+				text:   l.Text,
+				size:   l.FontSize,
+				bold:   isBoldFont(l.Font),
+				llx:    l.BBox[0],
+				lly:    l.BBox[1],
+				ury:    l.BBox[3],
+				hasBox: true,
+			}
+		}
+		return lines
+	}
+
+	var lines []mdLine
+	for _, text := range strings.Split(p.Text, "\n") {
+		lines = append(lines, mdLine{text: strings.TrimRight(text, " \t\r")})
+	}
+	return lines
+}
+
+// isBoldFont 根据字体名判断是否为粗体
+func isBoldFont(name string) bool {
+	name = strings.ToLower(name)
+	for _, w := range []string{"bold", "black", "heavy", "semibold", "demi"} {
+		if strings.Contains(name, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyFontSize 返回正文字号：按字符数加权出现最多的字号
+func bodyFontSize(pages [][]mdLine) float64 {
+	weights := make(map[float64]int)
+	for _, lines := range pages {
+		for _, l := range lines {
+			if l.size > 0 {
+				weights[roundHalf(l.size)] += utf8.RuneCountInString(l.text)
+			}
+		}
+	}
+
+	body, best := 0.0, -1
+	for size, w := range weights {
+		if w > best || (w == best && size < body) {
+			body, best = size, w
+		}
+	}
+	return body
+}
+
+// headingLevels 为大于正文的字号分配标题级别，字号越大级别越高，最多三级
+func headingLevels(pages [][]mdLine, body float64) map[float64]int {
+	levels := make(map[float64]int)
+	if body == 0 {
+		return levels
+	}
+
+	var sizes []float64
+	for _, lines := range pages {
+		for _, l := range lines {
+			size := roundHalf(l.size)
+			if size >= body*1.15 {
+				if _, ok := levels[size]; !ok {
+					levels[size] = 0
+					sizes = append(sizes, size)
+				}
+			}
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.Float64Slice(sizes)))
+	for i, size := range sizes {
+		levels[size] = min(i+1, 3)
+	}
+	return levels
+}
+
+// headingLevel 返回行的标题级别，0 表示不是标题
+func headingLevel(l mdLine, body float64, levels map[float64]int) int {
+	text := strings.TrimSpace(l.text)
+	if !l.hasBox || text == "" || utf8.RuneCountInString(text) > 120 {
+		return 0
+	}
+	if level, ok := levels[roundHalf(l.size)]; ok && level > 0 {
+		return level
+	}
+
+	// 与正文同字号的粗体短行视为最低一级标题
+	if l.bold && roundHalf(l.size) >= body && utf8.RuneCountInString(text) <= 80 && !endsSentence(text) {
+		return min(len(levels)+1, 4)
+	}
+	return 0
+}
+
+// markdownBlocks 把一页的行组织为标题、列表和段落
+func markdownBlocks(lines []mdLine, body float64, levels map[float64]int) []mdBlock {
+	var blocks []mdBlock
+	var para []string
+	var prev *mdLine
+	var listItem *mdLine
+
+	flush := func() {
+		if len(para) > 0 {
+			blocks = append(blocks, mdBlock{text: joinParagraph(para)})
+			para = nil
+		}
+	}
+
+	for i := range lines {
+		l := &lines[i]
+		text := strings.TrimSpace(l.text)
+		if text == "" {
+			flush()
+			listItem = nil
+			prev = nil
+			continue
+		}
+
+		if level := headingLevel(*l, body, levels); level > 0 {
+			flush()
+			listItem = nil
+			blocks = append(blocks, mdBlock{text: strings.Repeat("#", level) + " " + escapeMarkdown(text)})
+			prev = l
+			continue
+		}
+
+		if item, ok := listMarkdown(text); ok {
+			flush()
+			blocks = append(blocks, mdBlock{text: item, list: true})
+			listItem = l
+			prev = l
+			continue
+		}
+
+		// 缩进比列表项更深且紧接其后的行是列表项的续行
+		if listItem != nil && prev != nil && !paragraphBreak(*prev, *l) && isContinuation(*listItem, *l) {
+			last := &blocks[len(blocks)-1]
+			last.text = joinLines(last.text, text)
+			prev = l
+			continue
+		}
+		listItem = nil
+
+		if prev != nil && len(para) > 0 && paragraphBreak(*prev, *l) {
+			flush()
+		}
+		if len(para) == 0 {
+			text = escapeMarkdown(text)
+		}
+		para = append(para, text)
+		prev = l
+	}
+	flush()
+
+	return blocks
+}
+
+// listMarkdown 识别列表项并转换为Markdown语法
+func listMarkdown(text string) (string, bool) {
+	if m := bulletPattern.FindStringSubmatch(text); m != nil && m[1] != "" {
+		return "- " + m[1], true
+	}
+	if m := numberedPattern.FindStringSubmatch(text); m != nil {
+		return m[1] + ". " + m[2], true
+	}
+	if m := letteredPattern.FindStringSubmatch(text); m != nil {
+		return "- " + m[1] + ". " + m[2], true
+	}
+	return "", false
+}
+
+// isContinuation 判断行是否为列表项的续行
+func isContinuation(item, l mdLine) bool {
+	return item.hasBox && l.hasBox && l.llx > item.llx+2
+}
+
+// paragraphBreak 根据行间距判断两行之间是否分段
+func paragraphBreak(prev, l mdLine) bool {
+	if !prev.hasBox || !l.hasBox {
+		return false
+	}
+	height := prev.ury - prev.lly
+	if height <= 0 {
+		return false
+	}
+	gap := prev.lly - l.ury
+	return gap > height*0.8 || gap < -height*2
+}
+
+// joinParagraph 把换行的段落合并为一行
+func joinParagraph(lines []string) string {
+	text := lines[0]
+	for _, l := range lines[1:] {
+		text = joinLines(text, l)
+	}
+	return text
+}
+
+// joinLines 合并两行：去掉英文断词连字符，中日韩文字之间不加空格
+func joinLines(a, b string) string {
+	last, _ := utf8.DecodeLastRuneInString(a)
+	first, _ := utf8.DecodeRuneInString(b)
+
+	switch {
+	case last == '-' && unicode.IsLower(first):
+		return strings.TrimSuffix(a, "-") + b
+	case isCJK(last) || isCJK(first):
+		return a + b
+	default:
+		return a + " " + b
+	}
+}
+
+// isCJK 判断是否为中日韩文字或全角标点
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x3000 && r <= 0x303F) || (r >= 0xFF00 && r <= 0xFFEF)
+}
+
+// endsSentence 判断文本是否以句末标点结尾
+func endsSentence(text string) bool {
+	last, _ := utf8.DecodeLastRuneInString(text)
+	return strings.ContainsRune(".!?。！？;；:：,，", last)
+}
+
+// escapeMarkdown 转义行首会被解析为Markdown语法的字符
+func escapeMarkdown(text string) string {
+	if strings.HasPrefix(text, "#") || strings.HasPrefix(text, ">") {
+		return "\\" + text
+	}
+	return text
+}
+
+// writeBlocks 输出块，列表项之间不插入空行
+func writeBlocks(b *strings.Builder, blocks []mdBlock) {
+	for i, block := range blocks {
+		if i > 0 {
+			if block.list && blocks[i-1].list {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\n\n")
+			}
+		}
+		b.WriteString(block.text)
+	}
+	if len(blocks) > 0 {
+		b.WriteString("\n")
+	}
+}
+
+// roundHalf 把字号取整到0.5，消除浮点误差
+func roundHalf(v float64) float64 {
+	return math.Round(v*2) / 2
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// textLines 按从上到下的顺序排版行，行距为字号的1.2倍
+func textLines(lines ...TextBox) []TextBox {
+	y := 800.0
+	for i := range lines {
+		size := lines[i].FontSize
+		lines[i].BBox = [4]float64{72, y, 500, y + size}
+		y -= size * 1.2
+	}
+	return lines
+}
+
+func TestHeadingLevels(t *testing.T) {
+	page := []mdLine{
+		{text: strings.Repeat("正文", 50), size: 10},
+		{text: "标题", size: 24},
+		{text: "章", size: 16},
+		{text: "节", size: 14},
+		{text: "小节", size: 13.1},
+		{text: "略大", size: 11},
+	}
+	body := bodyFontSize([][]mdLine{page})
+	if body != 10 {
+		t.Fatalf("bodyFontSize = %v，want 10", body)
+	}
+
+	levels := headingLevels([][]mdLine{page}, body)
+	want := map[float64]int{24: 1, 16: 2, 14: 3, 13: 3}
+	if len(levels) != len(want) {
+		t.Fatalf("headingLevels = %v，want %v", levels, want)
+	}
+	for size, level := range want {
+		if levels[size] != level {
+			t.Errorf("字号 %v 的级别 = %d，want %d", size, levels[size], level)
+		}
+	}
+
+	if got := headingLevels([][]mdLine{{{text: "无坐标"}}}, 0); len(got) != 0 {
+		t.Errorf("没有字号信息时 headingLevels = %v，want 空", got)
+	}
+}
+
+func TestHeadingLevel(t *testing.T) {
+	levels := map[float64]int{24: 1, 16: 2}
+	tests := []struct {
+		name string
+		line mdLine
+		want int
+	}{
+		{name: "大字号", line: mdLine{text: "概述", size: 24.1, hasBox: true}, want: 1},
+		{name: "次大字号", line: mdLine{text: "背景", size: 16, hasBox: true}, want: 2},
+		{name: "正文字号粗体短行", line: mdLine{text: "注意事项", size: 10, bold: true, hasBox: true}, want: 3},
+		{name: "粗体句子", line: mdLine{text: "这是一句话。", size: 10, bold: true, hasBox: true}},
+		{name: "粗体长行", line: mdLine{text: strings.Repeat("长", 81), size: 10, bold: true, hasBox: true}},
+		{name: "小于正文的粗体", line: mdLine{text: "脚注", size: 8, bold: true, hasBox: true}},
+		{name: "正文", line: mdLine{text: "正文", size: 10, hasBox: true}},
+		{name: "过长的大字号行", line: mdLine{text: strings.Repeat("长", 121), size: 24, hasBox: true}},
+		{name: "没有坐标", line: mdLine{text: "概述", size: 24}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := headingLevel(tt.line, 10, levels); got != tt.want {
+				t.Errorf("headingLevel = %d，want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	result := &ExtractResult{Pages: []Page{
+		{Number: 1, Lines: textLines(
+			TextBox{Text: "年度报告", FontSize: 20, Font: "SimHei"},
+			TextBox{Text: "Overview", FontSize: 10, Font: "Arial-BoldMT"},
+			TextBox{Text: "The quick brown fox jumps over the lazy dog and keeps run-", FontSize: 10, Font: "ArialMT"},
+			TextBox{Text: "ning until the end of the line.", FontSize: 10, Font: "ArialMT"},
+			TextBox{Text: "• first item", FontSize: 10, Font: "ArialMT"},
+			TextBox{Text: "2) second item", FontSize: 10, Font: "ArialMT"},
+		)},
+		{Number: 2, Text: "# not a heading\n中文段落\n第二行\n\n- item"},
+	}}
+
+	want := `<a id="page-1"></a>
+
+# 年度报告
+
+## Overview
+
+The quick brown fox jumps over the lazy dog and keeps running until the end of the line.
+
+- first item
+2. second item
+
+<a id="page-2"></a>
+
+\# not a heading中文段落第二行
+
+- item
+`
+	if got := renderMarkdown(result); got != want {
+		t.Errorf("renderMarkdown =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestListMarkdown(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{in: "• 项目", want: "- 项目", ok: true},
+		{in: "* item", want: "- item", ok: true},
+		{in: "12. item", want: "12. item", ok: true},
+		{in: "3、项目", want: "3. 项目", ok: true},
+		{in: "b) item", want: "- b. item", ok: true},
+		{in: "2024. 年度", want: "", ok: false},
+		{in: "-5 度", want: "", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, ok := listMarkdown(tt.in)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("listMarkdown(%q) = %q, %v，want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lu4p/unipdf/v3/core"
+	pdf "github.com/lu4p/unipdf/v3/model"
+)
+
+// DocumentMeta 文档元数据，写入 <name>.meta.json 并包含在转换结果中
+type DocumentMeta struct {
+	Source     string            `json:"source"`
+	Backend    string            `json:"backend,omitempty"` // 产生文本的后端
+	PDFVersion string            `json:"pdfVersion,omitempty"`
+	TotalPages int               `json:"totalPages,omitempty"`
+	Encrypted  bool              `json:"encrypted"`
+	Encryption string            `json:"encryption,omitempty"` // 加密方式
+	Info       map[string]string `json:"info,omitempty"`       // Info字典，日期转换为RFC 3339格式
+	XMP        string            `json:"xmp,omitempty"`        // XMP元数据原文
+}
+
+// documentMeta 读取文档元数据并补充提取结果中的信息；
+// 读取失败时只记录日志，返回已获得的部分
+func documentMeta(ctx context.Context, data []byte, result *ExtractResult, opts ExtractOptions) *DocumentMeta {
+	meta, err := readDocumentMeta(ctx, data, opts)
+	if err != nil {
+		log.Printf("读取元数据失败: %v", err)
+	}
+	if meta == nil {
+		meta = &DocumentMeta{}
+	}
+	meta.Backend = result.Backend
+	if meta.TotalPages == 0 {
+		meta.TotalPages = result.TotalPages
+	}
+	return meta
+}
+
+// readDocumentMeta 用unipdf读取版本、加密方式、页数、Info字典和XMP元数据
+func readDocumentMeta(ctx context.Context, data []byte, opts ExtractOptions) (*DocumentMeta, error) {
+	return runUnipdf(ctx, func() (*DocumentMeta, error) {
+		pdfReader, err := pdf.NewPdfReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, newConvertError(CodeCorrupted, "创建PDF阅读器失败: %w", err)
+		}
+
+		meta := &DocumentMeta{PDFVersion: pdfReader.PdfVersion().String()}
+		encrypted, err := pdfReader.IsEncrypted()
+		if err != nil {
+			return meta, newConvertError(CodeCorrupted, "读取加密信息失败: %w", err)
+		}
+		if encrypted {
+			meta.Encrypted = true
+			meta.Encryption = pdfReader.GetEncryptionMethod()
+			// 未能解密时Info字典中的字符串仍是密文，只返回基本信息
+			if err := decryptReader(pdfReader, candidatePasswords(opts)); err != nil {
+				return meta, err
+			}
+		}
+
+		if n, err := pdfReader.GetNumPages(); err == nil {
+			meta.TotalPages = n
+		}
+
+		trailer, err := pdfReader.GetTrailer()
+		if err != nil {
+			return meta, newConvertError(CodeCorrupted, "读取trailer失败: %w", err)
+		}
+		info, ok := core.GetDict(lookupObject(pdfReader, trailer.Get("Info")))
+		if encrypted {
+			if info, err = decryptedInfo(data, opts); err != nil {
+				return meta, err
+			}
+			ok = info != nil
+		}
+		if ok {
+			meta.Info = infoStrings(info)
+		}
+		if catalog, ok := core.GetDict(lookupObject(pdfReader, trailer.Get("Root"))); ok {
+			if stream, ok := core.GetStream(lookupObject(pdfReader, catalog.Get("Metadata"))); ok {
+				xmp, err := core.DecodeStream(stream)
+				if err != nil {
+					return meta, newConvertError(CodeCorrupted, "解码XMP元数据失败: %w", err)
+				}
+				meta.XMP = strings.TrimSpace(string(xmp))
+			}
+		}
+		return meta, nil
+	})
+}
+
+// lookupObject 按对象号读取间接引用的对象。trailer中的引用直接解析时不会解密，
+// 通过 GetIndirectObjectByNumber 读取才能得到解密后的内容
+func lookupObject(pdfReader *pdf.PdfReader, obj core.PdfObject) core.PdfObject {
+	ref, ok := obj.(*core.PdfObjectReference)
+	if !ok {
+		return obj
+	}
+	resolved, err := pdfReader.GetIndirectObjectByNumber(int(ref.ObjectNumber))
+	if err != nil {
+		return nil
+	}
+	return resolved
+}
+
+// decryptedInfo 读取加密文件的Info字典。unipdf 解密后仍把Info对象当作明文，
+// 这里用单独的解析器取得密钥，再解密字典中的字符串
+func decryptedInfo(data []byte, opts ExtractOptions) (*core.PdfObjectDictionary, error) {
+	parser, err := core.NewParser(bytes.NewReader(data))
+	if err != nil {
+		return nil, newConvertError(CodeCorrupted, "创建PDF解析器失败: %w", err)
+	}
+	if _, err := parser.IsEncrypted(); err != nil {
+		return nil, newConvertError(CodeCorrupted, "读取加密信息失败: %w", err)
+	}
+	decrypted := false
+	for _, pw := range append([]string{""}, candidatePasswords(opts)...) {
+		if decrypted, err = parser.Decrypt([]byte(pw)); err != nil || decrypted {
+			break
+		}
+	}
+	if !decrypted {
+		return nil, newConvertError(CodeEncrypted, "无法解密Info字典")
+	}
+
+	ref, ok := parser.GetTrailer().Get("Info").(*core.PdfObjectReference)
+	if !ok {
+		return nil, nil
+	}
+	obj, err := parser.LookupByNumber(int(ref.ObjectNumber))
+	if err != nil {
+		return nil, newConvertError(CodeCorrupted, "读取Info字典失败: %w", err)
+	}
+	ind, ok := obj.(*core.PdfIndirectObject)
+	if !ok {
+		return nil, nil
+	}
+	info, ok := ind.PdfObject.(*core.PdfObjectDictionary)
+	if !ok {
+		return nil, nil
+	}
+	// 直接解密字典本身，绕过对Info对象的“已解密”标记
+	if err := parser.GetCrypter().Decrypt(info, ind.ObjectNumber, ind.GenerationNumber); err != nil {
+		return nil, newConvertError(CodeEncrypted, "解密Info字典失败: %w", err)
+	}
+	return info, nil
+}
+
+// infoStrings 把Info字典转换为字符串表，日期字段转换为RFC 3339格式
+func infoStrings(info *core.PdfObjectDictionary) map[string]string {
+	values := make(map[string]string)
+	for _, key := range info.Keys() {
+		obj := info.Get(key)
+		var value string
+		if s, ok := core.GetString(obj); ok {
+			value = s.Decoded()
+		} else if name, ok := core.GetNameVal(obj); ok {
+			value = name
+		} else {
+			continue
+		}
+
+		if key == "CreationDate" || key == "ModDate" {
+			if date, err := pdf.NewPdfDate(value); err == nil {
+				value = date.ToGoTime().Format(time.RFC3339)
+			}
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			values[string(key)] = value
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// metaName 返回输出文件对应的元数据文件名，如 report.txt 对应 report.meta.json
+func metaName(output string) string {
+	if i := strings.LastIndex(output, "."); i > strings.LastIndexAny(output, `/\`) {
+		output = output[:i]
+	}
+	return output + ".meta.json"
+}
+
+// renderMeta 生成元数据文件的内容
+func renderMeta(meta *DocumentMeta) (string, error) {
+	// XMP是XML原文，不转义其中的 <、>、&
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(meta); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
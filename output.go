@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// 输出格式
+const (
+	formatText     = "txt"
+	formatJSON     = "json"
+	formatMarkdown = "md"
+)
+
+// outputFormats 支持的输出格式及对应的文件扩展名
+var outputFormats = map[string]string{
+	formatText:     ".txt",
+	formatJSON:     ".json",
+	formatMarkdown: ".md",
+}
+
+// 文本坐标的粒度
+const (
+	positionsLines = "lines"
+	positionsWords = "words"
+	positionsAll   = "all"
+)
+
+// parseFormat 校验输出格式，空字符串表示纯文本
+func parseFormat(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return formatText, nil
+	}
+	if s == "markdown" {
+		s = formatMarkdown
+	}
+	if _, ok := outputFormats[s]; !ok {
+		return "", fmt.Errorf("未知的输出格式: %s（可用: txt, json, md）", s)
+	}
+	return s, nil
+}
+
+// parsePositions 校验文本坐标粒度，空字符串表示不输出坐标
+func parsePositions(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", "none":
+		return "", nil
+	case positionsLines, positionsWords, positionsAll:
+		return s, nil
+	}
+	return "", fmt.Errorf("未知的坐标粒度: %s（可用: none, lines, words, all）", s)
+}
+
+// wantLines 是否需要行的坐标；Markdown需要行的字号来识别标题
+func (opts ExtractOptions) wantLines() bool {
+	return opts.Positions == positionsLines || opts.Positions == positionsAll || opts.Format == formatMarkdown
+}
+
+// wantWords 是否需要输出单词的坐标
+func (opts ExtractOptions) wantWords() bool {
+	return opts.Positions == positionsWords || opts.Positions == positionsAll
+}
+
+// outputExt 返回输出格式对应的扩展名
+func outputExt(format string) string {
+	if ext, ok := outputFormats[format]; ok {
+		return ext
+	}
+	return ".txt"
+}
+
+// outputName 把PDF文件名替换为输出格式的扩展名
+func outputName(filename, format string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + outputExt(format)
+}
+
+// jsonDocument JSON输出的文档结构
+type jsonDocument struct {
+	Source     string `json:"source"`
+	Backend    string `json:"backend"`
+	TotalPages int    `json:"totalPages,omitempty"`
+	PageRange  string `json:"pageRange,omitempty"`
+	Pages      []Page `json:"pages"`
+}
+
+// renderOutput 按输出格式生成文件内容
+func renderOutput(source string, result *ExtractResult, opts ExtractOptions) (string, error) {
+	switch opts.Format {
+	case formatJSON:
+		doc := jsonDocument{
+			Source:     source,
+			Backend:    result.Backend,
+			TotalPages: result.TotalPages,
+			PageRange:  opts.PageRange.String(),
+			Pages:      result.Pages,
+		}
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	case formatMarkdown:
+		return renderMarkdown(result), nil
+	default:
+		return result.Text(), nil
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"mime/multipart"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+var (
+	// defaultOutputDir 本地保存的默认输出目录，由 output_dir 配置
+	defaultOutputDir = expandHome("~/Desktop/PDF转换结果")
+	// outputRoots 允许本地保存的根目录，由 output_roots 配置；客户端指定的输出目录必须位于其中之一
+	outputRoots []string
+)
+
+// allowedOutputRoots 返回允许的根目录，未配置时只允许默认输出目录
+func allowedOutputRoots() []string {
+	if len(outputRoots) > 0 {
+		return outputRoots
+	}
+	return []string{defaultOutputDir}
+}
+
+// parseOutputRoots 把 -output-root、-input-root 配置规范化为绝对路径
+func parseOutputRoots(roots []string) ([]string, error) {
+	var abs []string
+	for _, root := range roots {
+		dir, err := filepath.Abs(expandHome(root))
+		if err != nil {
+			return nil, err
+		}
+		abs = append(abs, dir)
+	}
+	return abs, nil
+}
+
+// expandHome 展开路径开头的 ~
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		homeDir, _ := os.UserHomeDir()
+		return filepath.Join(homeDir, p[1:])
+	}
+	return p
+}
+
+// sanitizeRelPath 校验客户端提供的相对路径：统一使用 / 分隔，
+// 拒绝绝对路径、盘符、".." 和控制字符
+func sanitizeRelPath(p string) (string, error) {
+	clean := strings.ReplaceAll(p, "\\", "/")
+	if strings.ContainsFunc(clean, unicode.IsControl) {
+		return "", newConvertError(CodeUnsafePath, "路径 %q 包含非法字符", p)
+	}
+	if strings.HasPrefix(clean, "/") || (len(clean) >= 2 && clean[1] == ':') {
+		return "", newConvertError(CodeUnsafePath, "路径 %q 不能是绝对路径", p)
+	}
+
+	var parts []string
+	for _, part := range strings.Split(clean, "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			return "", newConvertError(CodeUnsafePath, "路径 %q 试图离开输出目录", p)
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "", newConvertError(CodeUnsafePath, "路径 %q 为空", p)
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// sanitizePaths 校验表单中的所有相对路径，空字符串保持不变
+func sanitizePaths(paths []string) ([]string, error) {
+	clean := make([]string, len(paths))
+	for i, p := range paths {
+		if p == "" {
+			continue
+		}
+		var err error
+		if clean[i], err = sanitizeRelPath(p); err != nil {
+			return nil, err
+		}
+	}
+	return clean, nil
+}
+
+// outputTarget 本地保存的输出目录。写入都通过 os.Root 进行，
+// 即使目录中存在指向外部的符号链接也不会写到根目录之外。
+type outputTarget struct {
+	root string // 允许的根目录
+	dir  string // 输出目录相对根目录的路径，斜杠分隔，可以为空
+}
+
+// resolveOutputTarget 把客户端指定的输出目录解析到允许的根目录内；
+// 留空时使用默认输出目录，相对路径也相对默认输出目录
+func resolveOutputTarget(dir string) (outputTarget, error) {
+	roots := allowedOutputRoots()
+	dir = expandHome(strings.TrimSpace(dir))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(defaultOutputDir, dir)
+	}
+	dir = filepath.Clean(dir)
+
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+		if rel == "." {
+			rel = ""
+		}
+		return outputTarget{root: root, dir: filepath.ToSlash(rel)}, nil
+	}
+	return outputTarget{}, newConvertError(CodeUnsafePath, "输出目录 %s 不在允许的范围内（%s）", dir, strings.Join(roots, ", "))
+}
+
+// localOutputTarget 根据表单确定本地保存的输出目录并创建该目录；
+// 上传的是文件夹时，在输出目录下保留顶层文件夹名
+func localOutputTarget(form *multipart.Form) (outputTarget, error) {
+	target, err := resolveOutputTarget(formValue(form, "outputDir"))
+	if err != nil {
+		return target, err
+	}
+
+	if paths := form.Value["paths"]; len(paths) > 0 && paths[0] != "" {
+		rel, err := sanitizeRelPath(paths[0])
+		if err != nil {
+			return target, err
+		}
+		if top, _, ok := strings.Cut(rel, "/"); ok {
+			target.dir = path.Join(target.dir, top)
+		}
+	}
+
+	return target, target.prepare()
+}
+
+// path 输出目录的绝对路径
+func (t outputTarget) path() string {
+	return filepath.Join(t.root, filepath.FromSlash(t.dir))
+}
+
+// prepare 创建输出目录，拒绝经由符号链接离开根目录的路径
+func (t outputTarget) prepare() error {
+	if err := os.MkdirAll(t.root, 0755); err != nil {
+		return newConvertError(CodeIO, "创建输出目录失败: %w", err)
+	}
+	if err := t.checkSymlinks(t.dir); err != nil {
+		return err
+	}
+
+	root, err := os.OpenRoot(t.root)
+	if err != nil {
+		return newConvertError(CodeIO, "打开输出目录失败: %w", err)
+	}
+	defer root.Close()
+	if err := mkdirAllInRoot(root, t.dir); err != nil {
+		return newConvertError(CodeIO, "创建输出目录失败: %w", err)
+	}
+	return nil
+}
+
+// writeFile 在输出目录下写入文本文件，必要时创建子目录，返回文件的绝对路径
+func (t outputTarget) writeFile(rel, text string) (string, error) {
+	name := path.Join(t.dir, rel)
+	fullPath := filepath.Join(t.root, filepath.FromSlash(name))
+	if err := t.checkSymlinks(name); err != nil {
+		return "", err
+	}
+
+	root, err := os.OpenRoot(t.root)
+	if err != nil {
+		return "", newConvertError(CodeIO, "打开输出目录失败: %w", err)
+	}
+	defer root.Close()
+
+	if err := mkdirAllInRoot(root, path.Dir(name)); err != nil {
+		return "", newConvertError(CodeIO, "创建子目录失败 %s: %w", filepath.Dir(fullPath), err)
+	}
+
+	// 先写入同一目录下的临时文件再重命名，中途崩溃不会留下不完整的输出。
+	// 写入和重命名都经过 os.Root，检查之后父目录被换成符号链接也不会写到根目录之外；
+	// 重命名替换的是目录项本身，目标是符号链接时不会写到它指向的文件
+	tmpName := path.Join(path.Dir(name), "."+path.Base(name)+".tmp-"+randomToken()[:8])
+	file, err := root.OpenFile(filepath.FromSlash(tmpName), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", newConvertError(CodeIO, "写入文件失败 %s: %w", fullPath, err)
+	}
+	_, err = file.WriteString(text)
+	if err == nil {
+		// 重命名前落盘，断电后不会出现重命名已生效而内容为空的文件
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = root.Rename(filepath.FromSlash(tmpName), filepath.FromSlash(name))
+	}
+	if err != nil {
+		root.Remove(filepath.FromSlash(tmpName))
+		return "", newConvertError(CodeIO, "写入文件失败 %s: %w", fullPath, err)
+	}
+	return fullPath, nil
+}
+
+// stat 返回输出目录下已有文件的信息和绝对路径，文件不存在或无法读取时信息为nil
+func (t outputTarget) stat(rel string) (fs.FileInfo, string) {
+	name := path.Join(t.dir, rel)
+	fullPath := filepath.Join(t.root, filepath.FromSlash(name))
+	root, err := os.OpenRoot(t.root)
+	if err != nil {
+		return nil, fullPath
+	}
+	defer root.Close()
+	info, err := root.Stat(filepath.FromSlash(name))
+	if err != nil {
+		return nil, fullPath
+	}
+	return info, fullPath
+}
+
+// openJournal 在输出目录下打开检查点文件，同样限制在根目录内
+func (t outputTarget) openJournal(header journalHeader, resume bool) (*journal, error) {
+	root, err := os.OpenRoot(t.root)
+	if err != nil {
+		return nil, newConvertError(CodeIO, "打开输出根目录失败: %w", err)
+	}
+	defer root.Close()
+	name := filepath.FromSlash(path.Join(t.dir, journalName))
+	open := func(flag int) (*os.File, error) {
+		return root.OpenFile(name, flag, 0644)
+	}
+	return openJournal(open, header, resume)
+}
+
+// checkOutputs 转换前检查所有输出路径，任何一个离开根目录都拒绝整个请求
+func (t outputTarget) checkOutputs(filenames, relPaths []string, format string) error {
+	for i, filename := range filenames {
+		rel, err := localOutputRel(filename, relPaths[i], format)
+		if err != nil {
+			return err
+		}
+		if err := t.checkSymlinks(path.Join(t.dir, rel)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSymlinks 逐级解析已存在的路径，拒绝通过符号链接指向根目录之外的路径
+func (t outputTarget) checkSymlinks(name string) error {
+	realRoot, err := filepath.EvalSymlinks(t.root)
+	if err != nil {
+		return newConvertError(CodeIO, "解析输出目录失败: %w", err)
+	}
+
+	current := t.root
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." {
+			continue
+		}
+		current = filepath.Join(current, part)
+		real, err := filepath.EvalSymlinks(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return newConvertError(CodeIO, "解析路径失败 %s: %w", current, err)
+		}
+		if rel, err := filepath.Rel(realRoot, real); err != nil || !filepath.IsLocal(rel) {
+			return newConvertError(CodeUnsafePath, "路径 %s 通过符号链接指向输出目录之外", current)
+		}
+	}
+	return nil
+}
+
+// mkdirAllInRoot 在根目录内逐级创建目录
+func mkdirAllInRoot(root *os.Root, dir string) error {
+	current := ""
+	for _, part := range strings.Split(dir, "/") {
+		if part == "" || part == "." {
+			continue
+		}
+		current = path.Join(current, part)
+		if err := root.Mkdir(filepath.FromSlash(current), 0755); err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// localOutputRel 返回输出文件相对输出目录的路径；relPath 已经过 sanitizeRelPath 校验，
+// 其顶层文件夹已包含在输出目录中
+func localOutputRel(filename, relPath, format string) (string, error) {
+	if relPath == "" {
+		name, err := sanitizeRelPath(filename)
+		if err != nil {
+			return "", err
+		}
+		return outputName(name, format), nil
+	}
+	if _, rest, ok := strings.Cut(relPath, "/"); ok {
+		relPath = rest
+	}
+	return outputName(relPath, format), nil
+}
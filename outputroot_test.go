@@ -0,0 +1,172 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSanitizeRelPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string // 为空表示应拒绝
+	}{
+		{"报告/2024/年报.pdf", "报告/2024/年报.pdf"},
+		{"a/./b//c.pdf", "a/b/c.pdf"},
+		{`a\b\c.pdf`, "a/b/c.pdf"},
+		{"../etc/passwd", ""},
+		{"a/../../b.pdf", ""},
+		{`a\..\..\b.pdf`, ""},
+		{"/etc/passwd", ""},
+		{`\\server\share\a.pdf`, ""},
+		{"C:/Windows/a.pdf", ""},
+		{`C:\Windows\a.pdf`, ""},
+		{"C:a.pdf", ""},
+		{"a/b\x00.pdf", ""},
+		{"a/\nb.pdf", ""},
+		{"", ""},
+		{"./.", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := sanitizeRelPath(tt.path)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("sanitizeRelPath(%q) = %q，应拒绝", tt.path, got)
+				}
+				if errorCode(err) != CodeUnsafePath {
+					t.Fatalf("错误码 = %s，want %s", errorCode(err), CodeUnsafePath)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("sanitizeRelPath(%q) = %q, %v，want %q", tt.path, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizePathsKeepsEmpty(t *testing.T) {
+	got, err := sanitizePaths([]string{"", `a\b.pdf`})
+	if err != nil || got[0] != "" || got[1] != "a/b.pdf" {
+		t.Fatalf("sanitizePaths = %q, %v", got, err)
+	}
+	if _, err := sanitizePaths([]string{"a.pdf", "../b.pdf"}); err == nil {
+		t.Fatal("任一路径不安全时应拒绝")
+	}
+}
+
+// setupRoot 在临时目录中创建允许的根目录和根目录之外的目录，
+// 根目录中的 link 是指向外部目录的符号链接，inner 是指向 sub 的相对符号链接
+func setupRoot(t *testing.T) (root, outside string) {
+	t.Helper()
+	base := t.TempDir()
+	root = filepath.Join(base, "root")
+	outside = filepath.Join(base, "outside")
+	for _, dir := range []string{filepath.Join(root, "sub"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skipf("无法创建符号链接: %v", err)
+	}
+	if err := os.Symlink("sub", filepath.Join(root, "inner")); err != nil {
+		t.Fatal(err)
+	}
+	return root, outside
+}
+
+func TestResolveOutputTarget(t *testing.T) {
+	root, outside := setupRoot(t)
+	savedRoots, savedDefault := outputRoots, defaultOutputDir
+	t.Cleanup(func() { outputRoots, defaultOutputDir = savedRoots, savedDefault })
+	outputRoots, defaultOutputDir = []string{root}, root
+
+	tests := []struct {
+		dir     string
+		wantDir string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"sub", "sub", false},
+		{filepath.Join(root, "sub", "new"), "sub/new", false},
+		{"..", "", true},
+		{"sub/../../outside", "", true},
+		{filepath.Join(root, "..", "outside"), "", true},
+		{outside, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			target, err := resolveOutputTarget(tt.dir)
+			if tt.wantErr {
+				if errorCode(err) != CodeUnsafePath {
+					t.Fatalf("resolveOutputTarget(%q) = %+v, %v，应拒绝", tt.dir, target, err)
+				}
+				return
+			}
+			if err != nil || target.root != root || target.dir != tt.wantDir {
+				t.Fatalf("resolveOutputTarget(%q) = %+v, %v，want dir %q", tt.dir, target, err, tt.wantDir)
+			}
+		})
+	}
+}
+
+func TestCheckSymlinks(t *testing.T) {
+	root, _ := setupRoot(t)
+	target := outputTarget{root: root}
+
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"sub/a.txt", false},
+		{"sub/new/a.txt", false},
+		{"inner/a.txt", false},
+		{"link", true},
+		{"link/a.txt", true},
+		{"link/new/a.txt", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := target.checkSymlinks(tt.name)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("checkSymlinks(%q) = %v，wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if tt.wantErr && errorCode(err) != CodeUnsafePath {
+				t.Fatalf("错误码 = %s，want %s", errorCode(err), CodeUnsafePath)
+			}
+		})
+	}
+}
+
+func TestWriteFileThroughSymlinkedParent(t *testing.T) {
+	root, outside := setupRoot(t)
+
+	tests := []struct {
+		target  outputTarget
+		rel     string
+		wantErr bool
+	}{
+		{outputTarget{root: root, dir: "sub"}, "a/b.txt", false},
+		{outputTarget{root: root, dir: "inner"}, "c.txt", false},
+		{outputTarget{root: root, dir: "link"}, "d.txt", true},
+		{outputTarget{root: root}, "link/e.txt", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target.dir+"/"+tt.rel, func(t *testing.T) {
+			_, err := tt.target.writeFile(tt.rel, "text")
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("writeFile = %v，wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	entries, err := os.ReadDir(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("根目录之外出现了文件: %v", entries)
+	}
+}
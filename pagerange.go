@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pageSpan 连续的页码区间，To 为0表示直到最后一页
+type pageSpan struct {
+	From int
+	To   int
+}
+
+// PageRange 页码范围，如 "1-3,10,20-"。区间已排序并合并，nil 表示全部页面。
+type PageRange []pageSpan
+
+// parsePageRange 解析页码范围，空字符串表示全部页面
+func parsePageRange(s string) (PageRange, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	var spans []pageSpan
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		span, err := parsePageSpan(part)
+		if err != nil {
+			return nil, fmt.Errorf("无效的页码范围 %q: %w", part, err)
+		}
+		spans = append(spans, span)
+	}
+	if len(spans) == 0 {
+		return nil, fmt.Errorf("无效的页码范围 %q", s)
+	}
+
+	return mergeSpans(spans), nil
+}
+
+// parsePageSpan 解析单个区间："N"、"N-M" 或 "N-"
+func parsePageSpan(part string) (pageSpan, error) {
+	from, to, isRange := strings.Cut(part, "-")
+
+	start, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil || start < 1 {
+		return pageSpan{}, fmt.Errorf("页码必须是正整数")
+	}
+	if !isRange {
+		return pageSpan{From: start, To: start}, nil
+	}
+
+	to = strings.TrimSpace(to)
+	if to == "" {
+		return pageSpan{From: start}, nil
+	}
+	end, err := strconv.Atoi(to)
+	if err != nil || end < start {
+		return pageSpan{}, fmt.Errorf("结束页码必须不小于起始页码")
+	}
+	return pageSpan{From: start, To: end}, nil
+}
+
+// mergeSpans 排序并合并重叠或相邻的区间
+func mergeSpans(spans []pageSpan) PageRange {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].From < spans[j].From })
+
+	merged := PageRange{spans[0]}
+	for _, span := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if last.To == 0 {
+			break
+		}
+		if span.From > last.To+1 {
+			merged = append(merged, span)
+			continue
+		}
+		if span.To == 0 || span.To > last.To {
+			last.To = span.To
+		}
+	}
+	return merged
+}
+
+// String 返回规范化后的页码范围
+func (r PageRange) String() string {
+	parts := make([]string, len(r))
+	for i, span := range r {
+		switch {
+		case span.To == 0:
+			parts[i] = fmt.Sprintf("%d-", span.From)
+		case span.From == span.To:
+			parts[i] = strconv.Itoa(span.From)
+		default:
+			parts[i] = fmt.Sprintf("%d-%d", span.From, span.To)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// spans 返回要提取的区间，全部页面时返回 "1-"
+func (r PageRange) spans() []pageSpan {
+	if len(r) == 0 {
+		return []pageSpan{{From: 1}}
+	}
+	return r
+}
+
+// spanPastEnd 判断外部命令对某个区间的失败是否因为起始页超出了文档页数。
+// 区间按页码排序，前面的区间已经提取到页面时忽略该区间及之后的区间，与 resolve 的行为一致；
+// 没有任何页面时仍按页码范围无效处理
+func spanPastEnd(err error, extracted int) bool {
+	return extracted > 0 && errorCode(err) == CodeInvalidPageRange
+}
+
+// resolve 根据文档总页数返回要提取的页码列表
+func (r PageRange) resolve(numPages int) []int {
+	var pages []int
+	for _, span := range r.spans() {
+		to := span.To
+		if to == 0 || to > numPages {
+			to = numPages
+		}
+		for p := span.From; p <= to; p++ {
+			pages = append(pages, p)
+		}
+	}
+	return pages
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePageRange(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string // 规范化后的结果
+		wantErr bool
+	}{
+		{"", "", false},
+		{"  ", "", false},
+		{"3", "3", false},
+		{"1-3,10,20-", "1-3,10,20-", false},
+		{" 1 - 3 , 5 ", "1-3,5", false},
+		{"10,1-3", "1-3,10", false},
+		{"1-3,2-5", "1-5", false},
+		{"1-3,4-6", "1-6", false},
+		{"1-3,5-6", "1-3,5-6", false},
+		{"5,5,5", "5", false},
+		{"2-4,3", "2-4", false},
+		{"1-2,5-,3,7-9", "1-3,5-", false},
+		{"8-,2-", "2-", false},
+		{"1,,3", "1,3", false},
+		{",", "", true},
+		{"0", "", true},
+		{"-3", "", true},
+		{"a", "", true},
+		{"1-a", "", true},
+		{"5-3", "", true},
+		{"1-2-3", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			r, err := parsePageRange(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsePageRange(%q) = %q，应返回错误", tt.input, r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePageRange(%q): %v", tt.input, err)
+			}
+			if got := r.String(); got != tt.want {
+				t.Fatalf("parsePageRange(%q) = %q，want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeSpans(t *testing.T) {
+	tests := []struct {
+		name  string
+		spans []pageSpan
+		want  PageRange
+	}{
+		{"单个区间", []pageSpan{{2, 4}}, PageRange{{2, 4}}},
+		{"乱序", []pageSpan{{7, 7}, {1, 2}}, PageRange{{1, 2}, {7, 7}}},
+		{"相邻", []pageSpan{{1, 2}, {3, 3}, {4, 6}}, PageRange{{1, 6}}},
+		{"包含", []pageSpan{{1, 10}, {3, 4}}, PageRange{{1, 10}}},
+		{"开放区间吸收后续区间", []pageSpan{{3, 0}, {5, 9}, {1, 1}}, PageRange{{1, 1}, {3, 0}}},
+		{"开放区间延伸前一区间", []pageSpan{{1, 4}, {5, 0}}, PageRange{{1, 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeSpans(tt.spans); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("mergeSpans = %v，want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageRangeResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		numPages int
+		want     []int
+	}{
+		{"", 3, []int{1, 2, 3}},
+		{"2-", 4, []int{2, 3, 4}},
+		{"1-2,5", 6, []int{1, 2, 5}},
+		{"2-10", 4, []int{2, 3, 4}},
+		{"3,8-", 5, []int{3}},
+		{"6-", 5, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			r, err := parsePageRange(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.resolve(tt.numPages); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("resolve(%d) = %v，want %v", tt.numPages, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// defaultPasswords 服务端配置的候选密码，对所有请求生效
+var defaultPasswords []string
+
+// candidatePasswords 返回本次提取要尝试的候选密码，请求中的密码优先
+func candidatePasswords(opts ExtractOptions) []string {
+	passwords := make([]string, 0, len(opts.Passwords)+len(defaultPasswords))
+	seen := make(map[string]bool)
+	for _, list := range [][]string{opts.Passwords, defaultPasswords} {
+		for _, pw := range list {
+			if pw == "" || seen[pw] {
+				continue
+			}
+			seen[pw] = true
+			passwords = append(passwords, pw)
+		}
+	}
+	return passwords
+}
+
+// readPasswordFile 读取密码文件，每行一个密码，忽略空行
+func readPasswordFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var passwords []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		pw := strings.TrimRight(scanner.Text(), "\r")
+		if pw != "" {
+			passwords = append(passwords, pw)
+		}
+	}
+	return passwords, scanner.Err()
+}
+
+// passwordTrier 依次尝试空密码和候选密码，某个密码成功后后续调用沿用该密码
+type passwordTrier struct {
+	passwords  []string
+	candidates int
+}
+
+// newPasswordTrier 为一次提取准备要尝试的密码
+func newPasswordTrier(opts ExtractOptions) *passwordTrier {
+	candidates := candidatePasswords(opts)
+	return &passwordTrier{
+		passwords:  append([]string{""}, candidates...),
+		candidates: len(candidates),
+	}
+}
+
+// try 用当前密码执行 fn，密码错误时换下一个密码重试
+func (t *passwordTrier) try(fn func(password string) error) error {
+	var err error = newConvertError(CodeEncrypted, "文件已加密")
+	for len(t.passwords) > 0 {
+		err = fn(t.passwords[0])
+		if err == nil || errorCode(err) != CodeEncrypted {
+			return err
+		}
+		t.passwords = t.passwords[1:]
+	}
+	if t.candidates > 0 {
+		return newConvertError(CodeEncrypted, "文件已加密，提供的%d个密码均不正确", t.candidates)
+	}
+	return err
+}
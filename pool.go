@@ -0,0 +1,113 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// workers 批量转换的并发数，可通过 -workers 参数修改
+var workers = runtime.GOMAXPROCS(0)
+
+// multipartMemory 解析上传表单时使用的最大内存，超出部分写入临时文件，由 multipart_memory_mb 配置
+var multipartMemory int64 = 100 << 20
+
+// convertSlots 整个进程同时转换的文件数上限，等于 workers。HTTP请求和后台任务共用，
+// 多个请求同时到达时总并发仍不超过 workers
+var convertSlots = sync.OnceValue(func() chan struct{} {
+	return make(chan struct{}, max(workers, 1))
+})
+
+// withConvertSlot 占用一个转换名额执行 fn，名额已满时等待
+func withConvertSlot(fn func()) {
+	slots := convertSlots()
+	slots <- struct{}{}
+	defer func() { <-slots }()
+	fn()
+}
+
+// forEachParallel 使用最多 n 个goroutine并发执行 fn(0..count-1)，每次执行占用一个进程级的转换名额。
+// fn 应按下标写入结果切片，调用方再按顺序汇总，以保证输出顺序确定。
+func forEachParallel(n, count int, fn func(i int)) {
+	runWorkers(n, count, func(i int) {
+		withConvertSlot(func() { fn(i) })
+	})
+}
+
+// forEachOrdered 与 forEachParallel 相同，但 fn 返回的写出操作按下标顺序依次执行，
+// 最多缓存 n 个结果。写出前先释放转换名额，等待前面的文件时不占用名额
+func forEachOrdered(n, count int, fn func(i int) (write func())) {
+	order := newSequencer(n)
+	runWorkers(n, count, func(i int) {
+		var write func()
+		withConvertSlot(func() { write = fn(i) })
+		order.done(i, write)
+	})
+}
+
+// runWorkers 使用最多 n 个goroutine并发执行 fn(0..count-1)
+func runWorkers(n, count int, fn func(i int)) {
+	if n < 1 {
+		n = 1
+	}
+	if n > count {
+		n = count
+	}
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// sequencer 把并发完成的结果按下标顺序依次写出，如按输入顺序写入ZIP条目。
+// 先完成的结果等待前面的下标；超出窗口的调用会阻塞，最多只缓存 window 个结果
+type sequencer struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	next    int
+	window  int
+	pending map[int]func()
+}
+
+// newSequencer 创建窗口为 window 的 sequencer，通常等于并发数
+func newSequencer(window int) *sequencer {
+	s := &sequencer{window: max(window, 1), pending: make(map[int]func())}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// done 提交第 i 个结果的写出操作，write 为 nil 表示没有要写出的内容。
+// 每个下标必须调用且只调用一次；写出操作在锁内按顺序执行，不会并发
+func (s *sequencer) done(i int, write func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i >= s.next+s.window {
+		s.cond.Wait()
+	}
+	s.pending[i] = write
+	for {
+		write, ok := s.pending[s.next]
+		if !ok {
+			break
+		}
+		delete(s.pending, s.next)
+		if write != nil {
+			write()
+		}
+		s.next++
+	}
+	s.cond.Broadcast()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSequencerOrder(t *testing.T) {
+	for _, window := range []int{1, 2, 4} {
+		const count = 50
+		s := newSequencer(window)
+		var mu sync.Mutex
+		var got []int
+		maxPending := 0
+		runWorkers(window, count, func(i int) {
+			time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+			var write func()
+			if i%7 != 0 { // 部分下标没有输出
+				write = func() {
+					mu.Lock()
+					got = append(got, i)
+					mu.Unlock()
+				}
+			}
+			s.done(i, write)
+			s.mu.Lock()
+			maxPending = max(maxPending, len(s.pending))
+			s.mu.Unlock()
+		})
+
+		prev := -1
+		for _, i := range got {
+			if i <= prev {
+				t.Fatalf("window=%d: 写出顺序错误 %v", window, got)
+			}
+			prev = i
+		}
+		if want := count - (count+6)/7; len(got) != want {
+			t.Fatalf("window=%d: 写出 %d 个，期望 %d 个", window, len(got), want)
+		}
+		if maxPending > window {
+			t.Fatalf("window=%d: 缓存了 %d 个结果", window, maxPending)
+		}
+	}
+}
+
+func TestConvertSlotsSharedAcrossCalls(t *testing.T) {
+	limit := cap(convertSlots())
+	var running, peak atomic.Int32
+	work := func() {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+	}
+
+	// 多个请求同时转换，每个请求都使用 limit+2 个goroutine，其中一半按顺序写出
+	var wg sync.WaitGroup
+	var written [4][]int
+	for c := 0; c < 4; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if c%2 == 0 {
+				forEachParallel(limit+2, 20, func(int) { work() })
+				return
+			}
+			forEachOrdered(limit+2, 20, func(i int) func() {
+				work()
+				return func() { written[c] = append(written[c], i) }
+			})
+		}()
+	}
+	wg.Wait()
+
+	if p := int(peak.Load()); p > limit {
+		t.Fatalf("同时转换 %d 个文件，超过上限 %d", p, limit)
+	}
+	for c := 1; c < 4; c += 2 {
+		if len(written[c]) != 20 {
+			t.Fatalf("写出 %d 个，期望 20 个", len(written[c]))
+		}
+		for i, v := range written[c] {
+			if v != i {
+				t.Fatalf("写出顺序错误: %v", written[c])
+			}
+		}
+	}
+}
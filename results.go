@@ -0,0 +1,149 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// FileResult 单个文件的转换结果，返回给客户端并写入 manifest.json
+type FileResult struct {
+	Input        string           `json:"input"`
+	Output       string           `json:"output,omitempty"`
+	Backend      string           `json:"backend,omitempty"`
+	Pages        int              `json:"pages,omitempty"`
+	TotalPages   int              `json:"totalPages,omitempty"`
+	PageRange    string           `json:"pageRange,omitempty"`
+	OCRPages     []int            `json:"ocrPages,omitempty"`
+	PageBackends map[string][]int `json:"pageBackends,omitempty"`
+	FailedPages  []int            `json:"failedPages,omitempty"`
+	Meta         *DocumentMeta    `json:"meta,omitempty"`
+	Skipped      bool             `json:"skipped,omitempty"` // 按策略保留了已有的输出，没有重新转换
+	DurationMs   int64            `json:"durationMs"`
+	ErrorCode    ErrorCode        `json:"errorCode,omitempty"`
+	Error        string           `json:"error,omitempty"`
+}
+
+// OK 判断文件是否转换成功
+func (r *FileResult) OK() bool {
+	return r.Error == ""
+}
+
+// setError 记录失败原因
+func (r *FileResult) setError(err error) {
+	r.Error = err.Error()
+	r.ErrorCode = errorCode(err)
+}
+
+// batchReport 一批文件的转换汇总
+type batchReport struct {
+	Generated time.Time    `json:"generated"`
+	Total     int          `json:"total"`
+	Succeeded int          `json:"successCount"`
+	Skipped   int          `json:"skippedCount"`
+	Failed    int          `json:"failedCount"`
+	Files     []FileResult `json:"files"`
+}
+
+// newBatchReport 汇总每个文件的结果
+func newBatchReport(results []FileResult) batchReport {
+	report := batchReport{
+		Generated: time.Now(),
+		Total:     len(results),
+		Files:     results,
+	}
+	for i := range results {
+		switch {
+		case results[i].Skipped:
+			report.Skipped++
+		case results[i].OK():
+			report.Succeeded++
+		default:
+			report.Failed++
+		}
+	}
+	return report
+}
+
+// convertReader 打开并转换一个PDF，按输出格式生成内容，并记录所用后端、页数和耗时
+func convertReader(ctx context.Context, input string, open func() (io.ReadCloser, error), opts ExtractOptions) (string, FileResult) {
+	start := time.Now()
+	result := FileResult{Input: input, PageRange: opts.PageRange.String()}
+
+	text, err := func() (string, error) {
+		// 客户端断开或批量被取消后，剩余文件不再打开
+		if err := ctx.Err(); err != nil {
+			return "", newConvertError(classifyError(err), "转换已取消: %w", err)
+		}
+
+		file, err := open()
+		if err != nil {
+			return "", newConvertError(CodeIO, "打开文件失败: %w", err)
+		}
+		defer file.Close()
+
+		extracted, err := convertPDFReaderToText(ctx, file, opts)
+		if err != nil {
+			return "", err
+		}
+		result.Backend = extracted.Backend
+		result.Pages = len(extracted.Pages)
+		result.TotalPages = extracted.TotalPages
+		result.OCRPages = extracted.OCRPages()
+		result.PageBackends = extracted.PageBackends()
+		result.FailedPages = extracted.FailedPages()
+		if result.Meta = extracted.Meta; result.Meta != nil {
+			result.Meta.Source = input
+		}
+		content, err := renderOutput(input, extracted, opts)
+		if err != nil {
+			return "", newConvertError(CodeUnknown, "生成输出失败: %w", err)
+		}
+		if err := checkOutputSize(content); err != nil {
+			return "", err
+		}
+		return content, nil
+	}()
+
+	result.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.setError(err)
+	}
+	return text, result
+}
+
+// writeZipReport 在ZIP中写入 manifest.json，有失败时同时写入 errors.txt
+func writeZipReport(zipWriter *zip.Writer, results []FileResult) error {
+	report := newBatchReport(results)
+
+	manifest, err := zipWriter.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(manifest)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+
+	if report.Failed == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	for _, r := range results {
+		if !r.OK() {
+			fmt.Fprintf(&b, "%s: [%s] %s\n", r.Input, r.ErrorCode, r.Error)
+		}
+	}
+	errorsFile, err := zipWriter.Create("errors.txt")
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(errorsFile, b.String())
+	return err
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxFormValueSize 单个普通表单字段的最大长度
+const maxFormValueSize = 1 << 20
+
+// extraFormParts 除每个文件的 files、paths、mtimes 以外允许的字段数，用于提取参数和候选密码
+const extraFormParts = 64
+
+// maxFormParts 一次请求允许的最多部分数，每个文件最多对应 files、paths、mtimes 三个部分；
+// max_batch_files 为0时不限制
+func maxFormParts() int {
+	if maxBatchFiles <= 0 {
+		return 0
+	}
+	return 3*maxBatchFiles + extraFormParts
+}
+
+// uploadedFile 流式读取时保存到临时目录的上传文件
+type uploadedFile struct {
+	Index    int // 在 files 字段中的序号，对应 paths 的下标
+	Filename string
+	Path     string
+}
+
+// readMultipartStream 流式读取multipart请求：普通字段保存在内存中，
+// PDF文件逐个写入临时目录，其他文件直接丢弃，内存占用与上传总大小无关。
+// 部分总数受 maxFormParts 限制，每个普通字段受 maxFormValueSize 限制。
+func readMultipartStream(r *http.Request, dir string) (*multipart.Form, []uploadedFile, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	form := &multipart.Form{Value: make(map[string][]string)}
+	var uploads []uploadedFile
+	fileIndex := 0
+	limit := maxFormParts()
+
+	for parts := 1; ; parts++ {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if limit > 0 && parts > limit {
+			part.Close()
+			return nil, nil, newConvertError(CodeLimitExceeded, "表单的部分数超过上限 %d", limit)
+		}
+
+		name := part.FormName()
+		if part.FileName() == "" {
+			value, err := readFormValue(part)
+			part.Close()
+			if err != nil {
+				return nil, nil, fmt.Errorf("读取字段 %s 失败: %w", name, err)
+			}
+			form.Value[name] = append(form.Value[name], value)
+			continue
+		}
+
+		if name != "files" {
+			part.Close()
+			continue
+		}
+		index := fileIndex
+		fileIndex++
+
+		filename := part.FileName()
+		if !strings.HasSuffix(strings.ToLower(filename), ".pdf") {
+			part.Close()
+			continue
+		}
+		if err := checkBatchSize(len(uploads) + 1); err != nil {
+			part.Close()
+			return nil, nil, err
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("%d.pdf", index))
+		err = spoolPart(part, path)
+		part.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("保存上传文件失败 %s: %w", filename, err)
+		}
+		uploads = append(uploads, uploadedFile{Index: index, Filename: filename, Path: path})
+	}
+
+	return form, uploads, nil
+}
+
+// readFormValue 读取普通字段，超过 maxFormValueSize 时返回错误
+func readFormValue(part *multipart.Part) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(part, maxFormValueSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxFormValueSize {
+		return "", errors.New("字段内容过长")
+	}
+	return string(data), nil
+}
+
+// spoolPart 把上传的文件内容写入临时文件；超过 maxFileSize 的部分不再写入，
+// 转换时按超出限制处理
+func spoolPart(part *multipart.Part, dst string) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	var src io.Reader = part
+	if maxFileSize > 0 {
+		src = io.LimitReader(part, maxFileSize+1)
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadMultipartStream(t *testing.T) {
+	saved := maxBatchFiles
+	t.Cleanup(func() { maxBatchFiles = saved })
+	maxBatchFiles = 2
+
+	tests := []struct {
+		name      string
+		files     []string
+		fields    int    // 额外的 format 字段数
+		value     string // format 字段的内容，默认 txt
+		wantPDFs  int
+		wantLimit bool
+		wantErr   bool
+	}{
+		{name: "正常", files: []string{"a.pdf", "b.PDF"}, fields: 1, wantPDFs: 2},
+		{name: "丢弃非PDF文件", files: []string{"a.pdf", "notes.txt"}, wantPDFs: 1},
+		{name: "PDF数超过上限", files: []string{"a.pdf", "b.pdf", "c.pdf"}, wantLimit: true},
+		{name: "部分数超过上限", files: []string{"a.pdf"}, fields: 3*2 + extraFormParts, wantLimit: true},
+		{name: "字段过长", files: []string{"a.pdf"}, fields: 1, value: strings.Repeat("x", maxFormValueSize+1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			for _, name := range tt.files {
+				fw, err := mw.CreateFormFile("files", name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				fw.Write([]byte("%PDF-1.4"))
+				mw.WriteField("paths", "dir/"+name)
+			}
+			value := tt.value
+			if value == "" {
+				value = formatText
+			}
+			for i := 0; i < tt.fields; i++ {
+				mw.WriteField("format", value)
+			}
+			mw.Close()
+
+			r := httptest.NewRequest("POST", "/api/upload-convert", &body)
+			r.Header.Set("Content-Type", mw.FormDataContentType())
+			form, uploads, err := readMultipartStream(r, t.TempDir())
+
+			switch {
+			case tt.wantLimit:
+				if errorCode(err) != CodeLimitExceeded {
+					t.Fatalf("错误 = %v，want %s", err, CodeLimitExceeded)
+				}
+			case tt.wantErr:
+				if err == nil {
+					t.Fatal("应返回错误")
+				}
+			default:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(uploads) != tt.wantPDFs {
+					t.Fatalf("保存了 %d 个PDF，want %d", len(uploads), tt.wantPDFs)
+				}
+				if len(form.Value["paths"]) != len(tt.files) {
+					t.Fatalf("paths = %q", form.Value["paths"])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// runWatch 执行 watch 子命令：监视输入目录，新增或修改的PDF写入完成后转换到镜像的输出目录，
+// 失败的文件移到隔离目录。处理记录保存在状态文件中，重启后跳过没有变化的文件。
+func runWatch(args []string) int {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "用法: pdf2txt watch <目录> -o 输出目录 [选项]")
+		flags.PrintDefaults()
+	}
+	outputDir := flags.String("o", "", "输出目录，按输入目录的结构保存结果（必填）")
+	quarantine := flags.String("quarantine", "", "转换失败的PDF移到该目录（默认 <输出目录>/_failed）")
+	stateFile := flags.String("state", "", "状态文件，记录已处理的文件（默认 <输出目录>/.pdf2txt-watch.json）")
+	settle := flags.Duration("settle", 2*time.Second, "文件最后一次写入后等待多久才开始转换，避免读取未写完的文件")
+	recursive := flags.Bool("r", true, "监视子目录")
+	var includes, excludes stringList
+	flags.Var(&includes, "include", "只转换匹配该glob的文件（可重复，默认 *.pdf）")
+	flags.Var(&excludes, "exclude", "跳过匹配该glob的文件或目录（可重复）")
+	var ef extractFlags
+	ef.register(flags)
+
+	inputs, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitFailed
+	}
+	if len(inputs) != 1 || *outputDir == "" {
+		flags.Usage()
+		return exitFailed
+	}
+	if len(includes) == 0 {
+		includes = stringList{"*.pdf"}
+	}
+	if *settle < 0 {
+		fmt.Fprintln(os.Stderr, "-settle 不能小于0")
+		return exitFailed
+	}
+	opts, err := ef.options()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+
+	w := &watcher{
+		recursive: *recursive,
+		includes:  includes,
+		excludes:  excludes,
+		settle:    *settle,
+		opts:      opts,
+		timers:    make(map[string]*time.Timer),
+		active:    make(map[string]bool),
+		queue:     make(chan string),
+	}
+	if *quarantine == "" {
+		*quarantine = filepath.Join(*outputDir, "_failed")
+	}
+	for _, d := range []struct {
+		dst *string
+		src string
+	}{{&w.input, inputs[0]}, {&w.output, *outputDir}, {&w.quarantine, *quarantine}} {
+		if *d.dst, err = filepath.Abs(d.src); err != nil {
+			fmt.Fprintf(os.Stderr, "无效的路径 %s: %v\n", d.src, err)
+			return exitFailed
+		}
+	}
+	if info, err := os.Stat(w.input); err != nil || !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "%s 不是目录\n", w.input)
+		return exitFailed
+	}
+	// 输出目录和隔离目录中的文件都会被忽略，它们可以位于输入目录内，反过来则不行；
+	// 输入目录本身以 . 开头（如 .inbox）不影响监视
+	if w.inOutput(w.input) {
+		fmt.Fprintln(os.Stderr, "输入目录不能位于输出目录或隔离目录内")
+		return exitFailed
+	}
+	if *stateFile == "" {
+		*stateFile = filepath.Join(w.output, ".pdf2txt-watch.json")
+	}
+	if w.state, err = loadWatchState(*stateFile); err != nil {
+		fmt.Fprintf(os.Stderr, "读取状态文件失败: %v\n", err)
+		return exitFailed
+	}
+
+	// Ctrl-C 后正在转换的文件立即中断，未记录到状态文件中，下次启动时重新转换
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := w.run(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+// watcher 监视目录并转换其中的PDF
+type watcher struct {
+	input      string // 监视的目录
+	output     string // 输出目录，保持输入目录的结构
+	quarantine string // 转换失败的PDF移到这里
+	recursive  bool
+	includes   []string
+	excludes   []string
+	settle     time.Duration
+	opts       ExtractOptions
+	state      *watchState
+
+	ctx    context.Context
+	fsw    *fsnotify.Watcher
+	mu     sync.Mutex
+	timers map[string]*time.Timer // 等待写入完成的文件
+	active map[string]bool        // 正在转换的文件
+	queue  chan string
+}
+
+// run 启动监视，直到上下文取消
+func (w *watcher) run(ctx context.Context) error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("创建文件监视失败: %w", err)
+	}
+	defer fsw.Close()
+	w.ctx, w.fsw = ctx, fsw
+
+	var wg sync.WaitGroup
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case path := <-w.queue:
+					w.convert(path)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	w.state.prune(w.input)
+	if err := w.addTree(w.input); err != nil {
+		return fmt.Errorf("监视目录失败: %w", err)
+	}
+	log.Printf("开始监视 %s，输出到 %s\n", w.input, w.output)
+
+	for {
+		select {
+		case ev := <-fsw.Events:
+			w.handle(ev)
+		case err := <-fsw.Errors:
+			log.Printf("文件监视出错: %v\n", err)
+			// 事件队列溢出时可能漏掉了文件，重新扫描整个目录
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				if err := w.addTree(w.input); err != nil {
+					log.Printf("重新扫描失败: %v\n", err)
+				}
+			}
+		case <-ctx.Done():
+			w.mu.Lock()
+			for _, t := range w.timers {
+				t.Stop()
+			}
+			w.mu.Unlock()
+			wg.Wait()
+			log.Println("已停止监视")
+			return nil
+		}
+	}
+}
+
+// handle 处理单个文件系统事件
+func (w *watcher) handle(ev fsnotify.Event) {
+	if w.ignored(ev.Name) {
+		return
+	}
+	switch {
+	case ev.Has(fsnotify.Create):
+		info, err := os.Stat(ev.Name)
+		if err != nil {
+			return
+		}
+		if info.IsDir() {
+			// 新建或移入的目录中可能已经有文件
+			if w.recursive {
+				if err := w.addTree(ev.Name); err != nil {
+					log.Printf("监视目录失败 %s: %v\n", ev.Name, err)
+				}
+			}
+			return
+		}
+		w.schedule(ev.Name)
+	case ev.Has(fsnotify.Write) || ev.Has(fsnotify.Chmod):
+		// touch 等只修改时间的操作只产生 Chmod 事件；没有变化的文件在转换前会被跳过
+		w.schedule(ev.Name)
+	case ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename):
+		w.mu.Lock()
+		if t, ok := w.timers[ev.Name]; ok {
+			t.Stop()
+			delete(w.timers, ev.Name)
+		}
+		w.mu.Unlock()
+	}
+}
+
+// addTree 监视目录及其子目录，并安排其中尚未处理的PDF
+func (w *watcher) addTree(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && w.ignored(path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			rel, _ := filepath.Rel(w.input, path)
+			if path != w.input && (!w.recursive || matchAny(w.excludes, rel, d.Name())) {
+				return filepath.SkipDir
+			}
+			return w.fsw.Add(path)
+		}
+		if info, err := d.Info(); err == nil && !w.state.upToDate(w.rel(path), info) {
+			w.schedule(path)
+		}
+		return nil
+	})
+}
+
+// ignored 跳过隐藏文件（常见于写入中的临时文件）以及位于输出目录、隔离目录中的路径
+func (w *watcher) ignored(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), ".") || w.inOutput(path)
+}
+
+// inOutput 判断路径是否位于输出目录或隔离目录内
+func (w *watcher) inOutput(path string) bool {
+	for _, dir := range []string{w.output, w.quarantine} {
+		if rel, err := filepath.Rel(dir, path); err == nil && filepath.IsLocal(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// rel 返回相对输入目录的路径，作为状态文件中的键
+func (w *watcher) rel(path string) string {
+	rel, err := filepath.Rel(w.input, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// schedule 在文件停止写入 settle 时间后把它交给转换goroutine；期间的每次写入都会重新计时
+func (w *watcher) schedule(path string) {
+	rel := w.rel(path)
+	if !matchAny(w.includes, rel, filepath.Base(path)) || matchAny(w.excludes, rel, filepath.Base(path)) {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if t, ok := w.timers[path]; ok {
+		t.Reset(w.settle)
+		return
+	}
+	w.timers[path] = time.AfterFunc(w.settle, func() {
+		w.mu.Lock()
+		delete(w.timers, path)
+		w.mu.Unlock()
+		select {
+		case w.queue <- path:
+		case <-w.ctx.Done():
+		}
+	})
+}
+
+// convert 转换单个文件，成功时记录到状态文件，失败时移到隔离目录
+func (w *watcher) convert(path string) {
+	rel := w.rel(path)
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || w.state.upToDate(rel, info) {
+		return
+	}
+
+	// 同一文件正在转换，或者刚被修改过（写入方可能没有产生事件），稍后再试
+	w.mu.Lock()
+	ready := !w.active[path] && time.Since(info.ModTime()) >= w.settle
+	if ready {
+		w.active[path] = true
+	}
+	w.mu.Unlock()
+	if !ready {
+		w.schedule(path)
+		return
+	}
+	defer func() {
+		w.mu.Lock()
+		delete(w.active, path)
+		w.mu.Unlock()
+	}()
+
+	dir := filepath.Join(w.output, filepath.Dir(filepath.FromSlash(rel)))
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		err = newConvertError(CodeIO, "创建输出目录失败: %w", err)
+	} else {
+		err = convertPDFToText(w.ctx, path, dir, w.opts)
+	}
+	if w.ctx.Err() != nil {
+		return
+	}
+
+	rec := watchRecord{Size: info.Size(), ModTime: info.ModTime(), Converted: time.Now()}
+	if err == nil {
+		rec.Status = statusSuccess
+		rec.Output = filepath.Join(dir, outputName(filepath.Base(path), w.opts.Format))
+		log.Printf("转换成功: %s\n", path)
+	} else {
+		rec.Status = statusFailed
+		rec.ErrorCode, rec.Error = errorCode(err), err.Error()
+		log.Printf("转换失败: %s: [%s] %v\n", path, rec.ErrorCode, err)
+		if rec.Quarantine, err = w.quarantineFile(path, rel, rec); err != nil {
+			log.Printf("移动到隔离目录失败 %s: %v\n", path, err)
+		} else {
+			log.Printf("已移到隔离目录: %s\n", rec.Quarantine)
+		}
+	}
+	if err := w.state.set(rel, rec); err != nil {
+		log.Printf("保存状态文件失败: %v\n", err)
+	}
+}
+
+// quarantineFile 把转换失败的PDF移到隔离目录的相同相对位置，并在旁边写入失败原因；
+// 已有同名文件时在文件名后追加时间
+func (w *watcher) quarantineFile(path, rel string, rec watchRecord) (string, error) {
+	dst := filepath.Join(w.quarantine, filepath.FromSlash(rel))
+	if _, err := os.Lstat(dst); err == nil {
+		ext := filepath.Ext(dst)
+		dst = strings.TrimSuffix(dst, ext) + rec.Converted.Format("-20060102-150405") + ext
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+	if err := moveFile(path, dst); err != nil {
+		return "", err
+	}
+	reason := fmt.Sprintf("[%s] %s\n", rec.ErrorCode, rec.Error)
+	if err := os.WriteFile(dst+".error.txt", []byte(reason), 0644); err != nil {
+		log.Printf("写入失败原因失败 %s: %v\n", dst, err)
+	}
+	return dst, nil
+}
+
+// moveFile 移动文件，跨文件系统时改为复制后删除
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// watchRecord 单个文件最近一次的处理结果
+type watchRecord struct {
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"modTime"`
+	Status     string    `json:"status"` // success 或 failed
+	Output     string    `json:"output,omitempty"`
+	Quarantine string    `json:"quarantine,omitempty"` // 失败后移到的位置
+	ErrorCode  ErrorCode `json:"errorCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Converted  time.Time `json:"converted"`
+}
+
+// watchState 按相对输入目录的路径记录已处理的文件，每次变化后写回状态文件
+type watchState struct {
+	mu    sync.Mutex
+	path  string
+	Files map[string]watchRecord `json:"files"`
+}
+
+// loadWatchState 读取状态文件，文件不存在时返回空状态
+func loadWatchState(path string) (*watchState, error) {
+	s := &watchState{path: path, Files: make(map[string]watchRecord)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.Files == nil {
+		s.Files = make(map[string]watchRecord)
+	}
+	return s, nil
+}
+
+// upToDate 判断文件自上次成功转换后是否没有变化
+func (s *watchState) upToDate(rel string, info fs.FileInfo) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.Files[rel]
+	return ok && rec.Status == statusSuccess && rec.Size == info.Size() && rec.ModTime.Equal(info.ModTime())
+}
+
+// prune 删除源文件已不存在的成功记录；失败记录保留，用于查找隔离的文件
+func (s *watchState) prune(input string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for rel, rec := range s.Files {
+		if rec.Status != statusSuccess {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(input, filepath.FromSlash(rel))); errors.Is(err, fs.ErrNotExist) {
+			delete(s.Files, rel)
+		}
+	}
+}
+
+// set 更新记录并写回状态文件
+func (s *watchState) set(rel string, rec watchRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Files[rel] = rec
+	return s.save()
+}
+
+// save 原子地写入状态文件，进程中途退出也不会留下不完整的文件，调用方需持有 s.mu
+func (s *watchState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWatcherIgnored(t *testing.T) {
+	root := filepath.FromSlash("/srv/.inbox")
+	w := &watcher{
+		input:      root,
+		output:     filepath.Join(root, "out"),
+		quarantine: filepath.FromSlash("/srv/failed"),
+	}
+
+	tests := []struct {
+		path     string
+		ignored  bool
+		inOutput bool
+	}{
+		{"/srv/.inbox", true, false},
+		{"/srv/.inbox/a.pdf", false, false},
+		{"/srv/.inbox/sub/.a.pdf.part", true, false},
+		{"/srv/.inbox/out", true, true},
+		{"/srv/.inbox/out/a.txt", true, true},
+		{"/srv/.inbox/output/a.pdf", false, false},
+		{"/srv/failed/a.pdf", true, true},
+		{"/srv/other/a.pdf", false, false},
+	}
+	for _, tt := range tests {
+		path := filepath.FromSlash(tt.path)
+		t.Run(tt.path, func(t *testing.T) {
+			if got := w.ignored(path); got != tt.ignored {
+				t.Errorf("ignored = %v，want %v", got, tt.ignored)
+			}
+			if got := w.inOutput(path); got != tt.inOutput {
+				t.Errorf("inOutput = %v，want %v", got, tt.inOutput)
+			}
+		})
+	}
+}
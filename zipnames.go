@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ZIP条目重名时的处理策略
+const (
+	collisionSuffix = "suffix" // 追加序号，如 report (2).txt
+	collisionHash   = "hash"   // 追加源文件路径的短哈希，如 report-1a2b3c4d.txt
+	collisionFail   = "fail"   // 拒绝整个请求
+)
+
+// reservedZipEntries 程序自己写入ZIP的文件，转换结果不能占用
+var reservedZipEntries = []string{"manifest.json", "errors.txt"}
+
+// parseCollisionPolicy 校验重名策略，空字符串表示追加序号
+func parseCollisionPolicy(s string) (string, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "":
+		return collisionSuffix, nil
+	case collisionSuffix, collisionHash, collisionFail:
+		return s, nil
+	}
+	return "", fmt.Errorf("未知的重名策略: %s（可用: suffix, hash, fail）", s)
+}
+
+// cleanZipPath 把客户端提供的相对路径规范化为ZIP内的安全路径
+func cleanZipPath(p string) string {
+	p = path.Clean("/" + strings.ReplaceAll(p, "\\", "/"))
+	return strings.TrimPrefix(p, "/")
+}
+
+// zipEntryNames 为每个输入确定ZIP条目名：保留相对路径的目录结构，
+// 并按策略处理重名（不区分大小写，以兼容解压到macOS和Windows）。
+// meta 为 true 时每个条目旁还有 .meta.json 元数据文件，这些文件名同样不能重复
+func zipEntryNames(inputs []string, format, policy string, meta bool) ([]string, error) {
+	used := make(map[string]string)
+	for _, name := range reservedZipEntries {
+		used[name] = "(" + name + ")"
+	}
+
+	// taken 返回与条目名或其元数据文件名冲突的输入
+	taken := func(name string) (string, bool) {
+		if other, ok := used[strings.ToLower(name)]; ok {
+			return other, true
+		}
+		if meta {
+			if other, ok := used[strings.ToLower(metaName(name))]; ok {
+				return other, true
+			}
+		}
+		return "", false
+	}
+
+	names := make([]string, len(inputs))
+	for i, input := range inputs {
+		name := outputName(cleanZipPath(input), format)
+
+		if other, ok := taken(name); ok {
+			switch policy {
+			case collisionFail:
+				return nil, fmt.Errorf("ZIP条目重名: %s（%s 与 %s）", name, other, input)
+			case collisionHash:
+				name = hashedName(name, input)
+			}
+			name = uniqueName(name, taken)
+		}
+
+		used[strings.ToLower(name)] = input
+		if meta {
+			used[strings.ToLower(metaName(name))] = input
+		}
+		names[i] = name
+	}
+	return names, nil
+}
+
+// hashedName 在扩展名前追加源路径的短哈希
+func hashedName(name, input string) string {
+	sum := sha256.Sum256([]byte(input))
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "-" + hex.EncodeToString(sum[:4]) + ext
+}
+
+// uniqueName 在扩展名前追加序号直到不再重名
+func uniqueName(name string, taken func(string) (string, bool)) string {
+	if _, ok := taken(name); !ok {
+		return name
+	}
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if _, ok := taken(candidate); !ok {
+			return candidate
+		}
+	}
+}
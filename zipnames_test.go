@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestZipEntryNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs []string
+		format string
+		policy string
+		want   []string // nil 表示应拒绝
+	}{
+		{"不重名", []string{"a.pdf", "dir/a.pdf"}, formatText, collisionSuffix, []string{"a.txt", "dir/a.txt"}},
+		{"追加序号", []string{"a.pdf", "a.PDF", "A.pdf"}, formatText, collisionSuffix, []string{"a.txt", "a (2).txt", "A (3).txt"}},
+		{"序号跳过已占用的名称", []string{"a (2).pdf", "a.pdf", "a.pdf"}, formatText, collisionSuffix, []string{"a (2).txt", "a.txt", "a (3).txt"}},
+		{"规范化路径", []string{`dir\a.pdf`, "dir/./a.pdf", "../dir/a.pdf"}, formatText, collisionSuffix, []string{"dir/a.txt", "dir/a (2).txt", "dir/a (3).txt"}},
+		{"保留名称", []string{"manifest.pdf", "errors.json"}, formatJSON, collisionSuffix, []string{"manifest (2).json", "errors.json"}},
+		{"保留的错误清单", []string{"errors.pdf"}, formatText, collisionSuffix, []string{"errors (2).txt"}},
+		{"哈希", []string{"x/a.pdf", "y/../x/a.pdf"}, formatText, collisionHash, []string{"x/a.txt", "x/a-e93733fc.txt"}},
+		{"哈希不影响首个文件", []string{"a.pdf", "b.pdf"}, formatText, collisionHash, []string{"a.txt", "b.txt"}},
+		{"拒绝", []string{"a.pdf", "A.pdf"}, formatText, collisionFail, nil},
+		{"拒绝保留名称", []string{"errors.pdf"}, formatText, collisionFail, nil},
+		{"不重名时不拒绝", []string{"a.pdf", "b.pdf"}, formatText, collisionFail, []string{"a.txt", "b.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := zipEntryNames(tt.inputs, tt.format, tt.policy, false)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("zipEntryNames = %q，应拒绝", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("zipEntryNames = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZipEntryNamesReservesMetaSidecars(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs []string
+		format string
+		meta   bool
+		want   []string
+	}{
+		{"无元数据", []string{"report.pdf", "report.meta.pdf"}, "json", false, []string{"report.json", "report.meta.json"}},
+		{"元数据占用后者", []string{"report.pdf", "report.meta.pdf"}, "json", true, []string{"report.json", "report.meta (2).json"}},
+		{"元数据被前者占用", []string{"report.meta.pdf", "report.pdf"}, "json", true, []string{"report.meta.json", "report (2).json"}},
+		{"文本格式不冲突", []string{"report.pdf", "report.meta.pdf"}, "text", true, []string{"report.txt", "report.meta.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := zipEntryNames(tt.inputs, tt.format, collisionSuffix, tt.meta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("zipEntryNames = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := zipEntryNames([]string{"report.pdf", "report.meta.pdf"}, "json", collisionFail, true); err == nil {
+		t.Error("fail 策略下元数据文件重名应返回错误")
+	}
+}